@@ -0,0 +1,157 @@
+package impl
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Pair is an interface along with the concrete type that should implement it.
+// Iface may carry type arguments just like in Implement.
+type Pair struct {
+	IfacePath string `json:"ifacePath"`
+	Iface     string `json:"iface"`
+	ImplPath  string `json:"implPath"`
+	Impl      string `json:"impl"`
+}
+
+func (p Pair) String() string {
+	return p.IfacePath + "." + p.Iface + " -> " + p.ImplPath + "." + p.Impl
+}
+
+// ImplementBatch implements many interfaces onto their concrete types at once.
+// All packages are loaded in a single pass, and methods that end up in the
+// same file are merged into a single Implementation for that file. Methods
+// shared by several interfaces of the same concrete type are only generated once.
+// Files are returned in the order they were first needed and nothing is written to disk.
+func ImplementBatch(cfg *Config, pairs []Pair) ([]*Implementation, error) {
+	patterns := []string{}
+	for _, p := range pairs {
+		spec, err := parseTypeSpec(p.Iface)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", p, err)
+		}
+		patterns = append(patterns, p.IfacePath, p.ImplPath)
+		patterns = append(patterns, spec.importPaths()...)
+	}
+	s, err := NewSession(cfg, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	return s.ImplementBatch(pairs)
+}
+
+// ImplementBatch is like the package level ImplementBatch function
+// but uses the packages already loaded by the session.
+func (s *Session) ImplementBatch(pairs []Pair) ([]*Implementation, error) {
+	files := []string{}
+	inserts := map[string][]*insertion{}
+	visited := map[string]*visitedMethods{}
+	keys := []string{}
+	// packages that ErrNotImplemented was declared in by an earlier pair
+	declared := map[string]bool{}
+	// imports added to each file by earlier pairs
+	imports := map[string][]*AddedImport{}
+	// concrete types that an earlier pair declared with Config.New
+	declaredTypes := map[string]bool{}
+	// interfaces implemented onto each concrete type, which
+	// the comment of a type declared with Config.New lists
+	ifaceNames := map[string][]string{}
+	targets := make([]*target, len(pairs))
+	for i, p := range pairs {
+		key := p.ImplPath + "." + p.Impl
+		if visited[key] == nil {
+			visited[key] = newVisitedMethods()
+			keys = append(keys, key)
+		}
+		tt, err := prepare(s.cfg, s.load, p.IfacePath, p.Iface, p.ImplPath, p.Impl, visited[key])
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", p, err)
+		}
+		targets[i] = tt
+		for _, name := range tt.ifaceNames {
+			if !slices.Contains(ifaceNames[key], name) {
+				ifaceNames[key] = append(ifaceNames[key], name)
+			}
+		}
+	}
+	for i, p := range pairs {
+		tt, key := targets[i], p.ImplPath+"."+p.Impl
+		tt.declare = tt.declare && !declaredTypes[key]
+		if len(tt.missing) == 0 && !tt.declare {
+			continue
+		}
+		if tt.declare {
+			declaredTypes[key] = true
+			tt.ifaceNames = ifaceNames[key]
+		}
+		tt.hasErrNotImplemented = tt.hasErrNotImplemented || declared[tt.implPath]
+		// pairs that share a file share its imports too
+		// so that their names never collide.
+		tt.ct.addedImports = imports[tt.implFilename]
+		ins, err := tt.generate(s.cfg)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", p, err)
+		}
+		imports[tt.implFilename] = tt.ct.addedImports
+		if tt.needsErrNotImplemented {
+			declared[tt.implPath] = true
+		}
+		if _, ok := inserts[tt.implFilename]; !ok {
+			files = append(files, tt.implFilename)
+		}
+		inserts[tt.implFilename] = append(inserts[tt.implFilename], ins)
+	}
+	for _, key := range keys {
+		if err := visited[key].checkFilter(s.cfg); err != nil {
+			return nil, fmt.Errorf("%v: %w", key, err)
+		}
+	}
+	impls := []*Implementation{}
+	for _, f := range files {
+		imp, err := render(s.cfg, f, inserts[f])
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", f, err)
+		}
+		impls = append(impls, imp)
+	}
+	return impls, nil
+}
+
+// Interface is one of the interfaces to implement onto a single concrete
+// type with ImplementInterfaces. Name may carry type arguments just like in Implement.
+type Interface struct {
+	Path string `json:"path"`
+	Name string `json:"name"`
+}
+
+// ImplementInterfaces implements all of the given interfaces onto a single
+// concrete type. Methods shared by several interfaces are only generated once,
+// and a *ConflictError is returned if two interfaces require a method with the
+// same name but different signatures. Like Implement, it returns nil if the
+// concrete type already implements all of the interfaces.
+func ImplementInterfaces(cfg *Config, ifaces []Interface, implPath, impl string) (*Implementation, error) {
+	return singleImplementation(ImplementBatch(cfg, interfacePairs(ifaces, implPath, impl)))
+}
+
+// ImplementInterfaces is like the package level ImplementInterfaces
+// function but uses the packages already loaded by the session.
+func (s *Session) ImplementInterfaces(ifaces []Interface, implPath, impl string) (*Implementation, error) {
+	return singleImplementation(s.ImplementBatch(interfacePairs(ifaces, implPath, impl)))
+}
+
+func interfacePairs(ifaces []Interface, implPath, impl string) []Pair {
+	pairs := make([]Pair, len(ifaces))
+	for i, iface := range ifaces {
+		pairs[i] = Pair{IfacePath: iface.Path, Iface: iface.Name, ImplPath: implPath, Impl: impl}
+	}
+	return pairs
+}
+
+// singleImplementation returns the only file that a
+// batch implementing a single concrete type changes.
+func singleImplementation(impls []*Implementation, err error) (*Implementation, error) {
+	if err != nil || len(impls) == 0 {
+		return nil, err
+	}
+	return impls[0], nil
+}
@@ -0,0 +1,99 @@
+package impl
+
+import (
+	"fmt"
+	"go/types"
+	"strconv"
+	"strings"
+)
+
+// BodyKind defines the body of the generated methods
+type BodyKind int
+
+// Body kinds
+const (
+	// PanicBody generates methods that panic with "unimplemented"
+	PanicBody BodyKind = iota
+	// ZeroBody generates methods that return the zero value of each result
+	ZeroBody
+	// ErrorBody generates methods that return the zero value of each result,
+	// except for a last error result which wraps ErrNotImplemented. The
+	// ErrNotImplemented variable is declared in the concrete type's package
+	// if it does not exist yet.
+	ErrorBody
+)
+
+func (bk BodyKind) String() string {
+	switch bk {
+	case PanicBody:
+		return "panic"
+	case ZeroBody:
+		return "zero"
+	case ErrorBody:
+		return "error"
+	}
+	return fmt.Sprintf("BodyKind(%d)", int(bk))
+}
+
+// ParseBodyKind parses "panic", "zero" or "error" into a BodyKind
+func ParseBodyKind(s string) (BodyKind, error) {
+	for _, bk := range []BodyKind{PanicBody, ZeroBody, ErrorBody} {
+		if strings.EqualFold(s, bk.String()) {
+			return bk, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown body kind %q, expected panic, zero or error", s)
+}
+
+// errNotImplemented is the name of the error
+// returned by methods generated with ErrorBody.
+const errNotImplemented = "ErrNotImplemented"
+
+// body returns the statements of a generated method according to the
+// configured body kind, or the call to the field it delegates to. Any import it needs is added to the concrete
+// type's file, which is why it is only called if the template uses it.
+func (tt *target) body(cfg *Config, md *MethodData) (string, error) {
+	if cfg != nil && cfg.Delegate != "" {
+		return delegateCall(md, cfg.Delegate), nil
+	}
+	kind := PanicBody
+	if cfg != nil {
+		kind = cfg.Body
+	}
+	switch kind {
+	case PanicBody:
+		return `panic("unimplemented")`, nil
+	case ZeroBody, ErrorBody:
+	default:
+		return "", fmt.Errorf("unknown body kind: %v", kind)
+	}
+	if len(md.Results) == 0 {
+		return "", nil
+	}
+	values := make([]string, len(md.Results))
+	for i, r := range md.Results {
+		zero, err := tt.ct.zero(r)
+		if err != nil {
+			return "", err
+		}
+		values[i] = zero
+	}
+	if kind == ErrorBody && md.ReturnsError {
+		tt.needsErrNotImplemented = !tt.hasErrNotImplemented
+		// the parameters, results and receiver shadow imports within the method
+		fmtName := tt.ct.importName(types.NewPackage("fmt", "fmt"), md.localNames()...)
+		if md.ReceiverName == "" {
+			values[len(values)-1] = fmt.Sprintf("%s.Errorf(%s, %s)", fmtName, strconv.Quote(md.ReceiverType+"."+md.Name+": %w"), errNotImplemented)
+		} else {
+			values[len(values)-1] = fmt.Sprintf("%s.Errorf(%s, %s, %s)", fmtName, strconv.Quote("%T."+md.Name+": %w"), md.ReceiverName, errNotImplemented)
+		}
+	}
+	return "return " + strings.Join(values, ", "), nil
+}
+
+// errNotImplementedDecl declares ErrNotImplemented
+// in the concrete type's package.
+func (tt *target) errNotImplementedDecl() string {
+	errorsName := tt.ct.importName(types.NewPackage("errors", "errors"))
+	return fmt.Sprintf("// %s is returned by the methods that are not implemented yet.\nvar %s = %s.New(\"not implemented\")\n", errNotImplemented, errNotImplemented, errorsName)
+}
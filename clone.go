@@ -0,0 +1,67 @@
+package impl
+
+import (
+	"go/ast"
+	"go/types"
+	"reflect"
+)
+
+var (
+	identType  = reflect.TypeOf(&ast.Ident{})
+	objectType = reflect.TypeOf(&ast.Object{})
+	scopeType  = reflect.TypeOf(&ast.Scope{})
+)
+
+// cloneExpr returns a deep copy of expr so that it can be rewritten
+// without modifying the original syntax tree. Since type information
+// is keyed by identifier, it also returns the objects used by every
+// identifier of the copy according to info.
+func cloneExpr(expr ast.Expr, info *types.Info) (ast.Expr, map[*ast.Ident]types.Object) {
+	uses := map[*ast.Ident]types.Object{}
+	cp := cloneValue(reflect.ValueOf(expr), func(orig, cp *ast.Ident) {
+		if obj, ok := info.Uses[orig]; ok {
+			uses[cp] = obj
+		}
+	})
+	return cp.Interface().(ast.Expr), uses
+}
+
+func cloneValue(v reflect.Value, onIdent func(orig, cp *ast.Ident)) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		// ast.Objects and ast.Scopes point back into the rest of
+		// the file and are deprecated, so they are shared instead.
+		if v.IsNil() || v.Type() == objectType || v.Type() == scopeType {
+			return v
+		}
+		cp := reflect.New(v.Type().Elem())
+		cp.Elem().Set(cloneValue(v.Elem(), onIdent))
+		if v.Type() == identType {
+			onIdent(v.Interface().(*ast.Ident), cp.Interface().(*ast.Ident))
+		}
+		return cp
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.New(v.Type()).Elem()
+		cp.Set(cloneValue(v.Elem(), onIdent))
+		return cp
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			cp.Index(i).Set(cloneValue(v.Index(i), onIdent))
+		}
+		return cp
+	case reflect.Struct:
+		cp := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			cp.Field(i).Set(cloneValue(v.Field(i), onIdent))
+		}
+		return cp
+	}
+	return v
+}
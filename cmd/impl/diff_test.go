@@ -0,0 +1,62 @@
+package main
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	old := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	new := "a\nb\nc\nd\ne\nf\ng\nh\nX\ni\nj\nk\n"
+	want := `diff -u f.go.orig f.go
+--- f.go.orig
++++ f.go
+@@ -6,5 +6,7 @@
+ f
+ g
+ h
++X
+ i
+ j
++k
+`
+	got := string(unifiedDiff("f.go", []byte(old), []byte(new)))
+	if got != want {
+		t.Fatalf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestUnifiedDiffHunks(t *testing.T) {
+	old := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"
+	new := "0\n1\n2\n3\n4\n5\n6\n7\n8\n10\n"
+	want := `diff -u f.go.orig f.go
+--- f.go.orig
++++ f.go
+@@ -1,3 +1,4 @@
++0
+ 1
+ 2
+ 3
+@@ -6,5 +7,4 @@
+ 6
+ 7
+ 8
+-9
+ 10
+`
+	got := string(unifiedDiff("f.go", []byte(old), []byte(new)))
+	if got != want {
+		t.Fatalf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestUnifiedDiffNewFile(t *testing.T) {
+	want := `diff -u f.go.orig f.go
+--- f.go.orig
++++ f.go
+@@ -0,0 +1,2 @@
++package f
++
+`
+	got := string(unifiedDiff("f.go", nil, []byte("package f\n\n")))
+	if got != want {
+		t.Fatalf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
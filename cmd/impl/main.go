@@ -1,40 +1,93 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 
+	"golang.org/x/tools/txtar"
 	"marwan.io/impl"
 )
 
 const usage = `impl generates interface method stubs for a defined type
 Usage:
 	impl -iface=path.to/my/pkg.MyInterface -impl=path.to/my/pkg.MyTime
+	impl -iface='path.to/my/pkg.Store[string, *models.User]' -impl=path.to/my/pkg.MyStore # generic interfaces
+	impl -iface=io.Closer -impl=./store/db.go:42:7 # the type declared at file:line:column
+	impl -iface=io.Closer -impl=path.to/my/pkg.MyStore -o=store_closer.go # write the methods to their own file
+	impl -iface=io.Closer -impl=path.to/my/pkg.MyCloser -new -constructor # declare MyCloser in mycloser.go along with its methods
+	impl -iface=net/http.Handler -iface=io.Closer -impl=path.to/my/pkg.MyHandler # several interfaces at once
+	impl batch pairs.txt # implements every "iface impl" pair listed in pairs.txt, or stdin if no file is given
 	impl list # lists all available interfaces to implement
 	impl list -path=io.Writer # list all available interfaces within io.Writer and its dependencies
 `
 
+// ifaceArgs are the paths to the interface declarations set by -iface
+var ifaceArgs stringList
+
 var (
-	ifaceArg = flag.String("iface", "", "path to the interface declaration: path.to/my/pkg.MyInterface")
-	implArg  = flag.String("impl", "", "path to the implementation type: path.to/my/pkg.MyTime")
+	implArg  = flag.String("impl", "", "path to the implementation type: path.to/my/pkg.MyTime, or its position: ./my/pkg/time.go:42:7")
 	write    = flag.Bool("w", false, "rewrite the file instead of printing to stdout")
+	showDiff = flag.Bool("d", false, "print a unified diff of the changes instead of the whole file")
+	listOnly = flag.Bool("l", false, "list the files that would change and exit with status 1 if there are any")
 	wantJSON = flag.Bool("json", false, "print response infromation in json format")
 	path     = flag.String("path", "", "the path where you want to list interfaces (i.e. impl list -path=io.Writer)")
+	tags     = flag.String("tags", "", "comma separated list of build tags to apply when loading packages")
+	receiver = flag.String("receiver", "pointer", "receiver kind of the generated methods: pointer, value or auto to match the existing methods")
+	recvName = flag.String("receiver-name", "", "receiver name when the type has no methods to copy it from, defaults to the type's first letter, _ for anonymous receivers")
+	overlay  = flag.String("overlay", "", "file (or - for stdin) with unsaved file contents, as a txtar archive or a go build -overlay JSON file")
+	doc      = flag.String("doc", "implements", "doc comment of the generated methods: implements, copy to add the interface method's doc beneath it, or replace to use the interface method's doc instead")
+	outFile  = flag.String("o", "", "file in the implementation type's package to write the methods to, which is created if it does not exist")
+	newType  = flag.Bool("new", false, "declare the implementation type as an empty struct if it does not exist, in the -o file or a new file named after the type")
+	newFunc  = flag.Bool("constructor", false, "with -new, also declare a NewMyType function that returns the new type")
+	place    = flag.String("placement", "type", "where to insert the generated methods: after the type declaration, after its last methods in the same file, at the end of the file, or after an //impl:insert-here marker comment")
+	order    = flag.String("order", "embedded", "order of the generated methods: embedded interfaces first, declaration, alphabetical, or grouped by the interface that declares them")
+	chain    = flag.Bool("chain", false, "attribute methods to the chain of embedded interfaces that declares them, such as Partier > io.ReadCloser > io.Reader")
+	body     = flag.String("body", "panic", "body of the generated methods: panic, zero to return zero values, or error to also return an error wrapping ErrNotImplemented")
+	methods  = flag.String("methods", "", "comma separated list of the only interface methods to implement")
+	exclude  = flag.String("exclude", "", "comma separated list of interface methods not to implement")
+	delegate = flag.String("delegate", "", "name of a field of the implementation type that the generated methods forward their calls to")
+	tmplFile = flag.String("template", "", "file with a text/template to generate each method with, see impl.MethodData for its data")
 )
 
+func init() {
+	flag.Var(&ifaceArgs, "iface", "path to the interface declaration: path.to/my/pkg.MyInterface, can be repeated to implement several interfaces")
+}
+
+// stringList is a flag that can be repeated
+type stringList []string
+
+func (sl *stringList) String() string {
+	return strings.Join(*sl, ",")
+}
+
+func (sl *stringList) Set(s string) error {
+	*sl = append(*sl, s)
+	return nil
+}
+
 func main() {
 	flag.Usage = func() {
-		fmt.Println(usage)
+		fmt.Print(usage)
 		flag.PrintDefaults()
 	}
 	flag.Parse()
-	if err := run(); err != nil {
+	err := run()
+	if err == errChanges {
+		os.Exit(1)
+	}
+	if err != nil {
 		log.Fatal(err)
 	}
 }
@@ -45,6 +98,8 @@ func run() error {
 		switch args[0] {
 		case "list":
 			return list()
+		case "batch":
+			return batch(args[1:])
 		default:
 			return fmt.Errorf("unrecognized command: %v", args[0])
 		}
@@ -57,7 +112,11 @@ func list() error {
 	if err != nil {
 		return err
 	}
-	ifaces, err := impl.ListInterfaces(path)
+	cfg, err := config()
+	if err != nil {
+		return err
+	}
+	ifaces, err := impl.ListInterfacesWithConfig(cfg, path)
 	if err != nil {
 		return err
 	}
@@ -72,33 +131,306 @@ func list() error {
 }
 
 func implement() error {
-	ifaceArg := *ifaceArg
-	implArg := *implArg
-	idx := strings.LastIndex(ifaceArg, ".")
-	ifacePath := ifaceArg[:idx]
-	iface := ifaceArg[idx+1:]
-	idx = strings.LastIndex(implArg, ".")
-	implPath := implArg[:idx]
-	implName := implArg[idx+1:]
-	impl, err := impl.Implement(ifacePath, iface, implPath, implName)
+	if len(ifaceArgs) == 0 {
+		return fmt.Errorf("missing -iface")
+	}
+	ifaces := []impl.Interface{}
+	for _, arg := range ifaceArgs {
+		ifacePath, iface, err := splitTypePath(arg)
+		if err != nil {
+			return err
+		}
+		ifaces = append(ifaces, impl.Interface{Path: ifacePath, Name: iface})
+	}
+	cfg, err := config()
 	if err != nil {
 		return err
 	}
-	if impl == nil || len(impl.FileContent) == 0 {
-		return nil
+	implPath, implName, err := splitImplArg(cfg, *implArg)
+	if err != nil {
+		return err
 	}
-	if *write {
-		return ioutil.WriteFile(impl.File, impl.FileContent, 0660)
+	var imp *impl.Implementation
+	if len(ifaces) == 1 {
+		imp, err = impl.ImplementWithConfig(cfg, ifaces[0].Path, ifaces[0].Name, implPath, implName)
+	} else {
+		imp, err = impl.ImplementInterfaces(cfg, ifaces, implPath, implName)
 	}
-	if *wantJSON {
-		bts, _ := json.MarshalIndent(impl, "", "\t")
+	if err != nil {
+		return err
+	}
+	if imp == nil || len(imp.FileContent) == 0 {
+		return nil
+	}
+	if *wantJSON && !*write && !*listOnly && !*showDiff {
+		bts, _ := json.MarshalIndent(imp, "", "\t")
 		fmt.Printf("%s\n", bts)
 		return nil
 	}
-	fmt.Printf("%s", impl.FileContent)
+	return output(cfg, []*impl.Implementation{imp})
+}
+
+// errChanges is returned by -l when there are files to change
+var errChanges = errors.New("files would change")
+
+// output writes, diffs, lists or prints the implemented files
+// depending on the command line flags.
+func output(cfg *impl.Config, impls []*impl.Implementation) error {
+	switch {
+	case *write:
+		for _, imp := range impls {
+			if err := writeFile(imp.File, imp.FileContent); err != nil {
+				return err
+			}
+		}
+	case *listOnly:
+		for _, imp := range impls {
+			fmt.Println(imp.File)
+		}
+		if len(impls) > 0 {
+			return errChanges
+		}
+	case *showDiff:
+		for _, imp := range impls {
+			os.Stdout.Write(unifiedDiff(imp.File, imp.Original, imp.FileContent))
+		}
+	case *wantJSON:
+		bts, _ := json.MarshalIndent(impls, "", "\t")
+		fmt.Printf("%s\n", bts)
+	default:
+		for _, imp := range impls {
+			fmt.Printf("%s", imp.FileContent)
+		}
+	}
 	return nil
 }
 
+// batch implements every pair read from the given file, or stdin.
+// Pairs are either one "iface impl" pair per line, such as
+// "io.Writer path.to/my/pkg.MyType", or a JSON array of
+// {"iface": "io.Writer", "impl": "path.to/my/pkg.MyType"} objects.
+func batch(args []string) error {
+	var r io.Reader = os.Stdin
+	if len(args) > 0 && args[0] != "-" && *overlay == "-" {
+		return fmt.Errorf("cannot read both pairs and -overlay from stdin")
+	}
+	if len(args) > 0 && args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	cfg, err := config()
+	if err != nil {
+		return err
+	}
+	pairs, err := readPairs(cfg, r)
+	if err != nil {
+		return err
+	}
+	impls, err := impl.ImplementBatch(cfg, pairs)
+	if err != nil {
+		return err
+	}
+	return output(cfg, impls)
+}
+
+func readPairs(cfg *impl.Config, r io.Reader) ([]impl.Pair, error) {
+	bts, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	type pairArg struct{ Iface, Impl string }
+	args := []pairArg{}
+	if trimmed := bytes.TrimSpace(bts); bytes.HasPrefix(trimmed, []byte("[")) {
+		if err := json.Unmarshal(trimmed, &args); err != nil {
+			return nil, fmt.Errorf("json decode err: %v", err)
+		}
+	} else {
+		for i, line := range strings.Split(string(bts), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			// the interface may have type arguments with spaces,
+			// but the implementation is always the last field.
+			idx := strings.LastIndexAny(line, " \t")
+			if idx == -1 {
+				return nil, fmt.Errorf("line %d: expected an \"iface impl\" pair but got %q", i+1, line)
+			}
+			args = append(args, pairArg{Iface: strings.TrimSpace(line[:idx]), Impl: line[idx+1:]})
+		}
+	}
+	pairs := []impl.Pair{}
+	for _, arg := range args {
+		ifacePath, iface, err := splitTypePath(arg.Iface)
+		if err != nil {
+			return nil, err
+		}
+		implPath, implName, err := splitImplArg(cfg, arg.Impl)
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, impl.Pair{IfacePath: ifacePath, Iface: iface, ImplPath: implPath, Impl: implName})
+	}
+	return pairs, nil
+}
+
+// writeFile atomically replaces the content of the given file
+// by writing to a temporary file in the same directory and renaming it.
+func writeFile(name string, content []byte) error {
+	perm := os.FileMode(0660)
+	if fi, err := os.Stat(name); err == nil {
+		perm = fi.Mode().Perm()
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+".impl")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// config returns the library configuration from the command line flags
+func config() (*impl.Config, error) {
+	cfg := &impl.Config{}
+	if *tags != "" {
+		cfg.Tags = strings.Split(*tags, ",")
+	}
+	if *methods != "" {
+		cfg.Methods = strings.Split(*methods, ",")
+	}
+	if *exclude != "" {
+		cfg.Exclude = strings.Split(*exclude, ",")
+	}
+	var err error
+	cfg.Receiver, err = impl.ParseReceiverKind(*receiver)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ReceiverName = *recvName
+	cfg.Output = *outFile
+	cfg.New = *newType
+	cfg.Constructor = *newFunc
+	cfg.Doc, err = impl.ParseDocKind(*doc)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Chain = *chain
+	cfg.Placement, err = impl.ParsePlacementKind(*place)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Order, err = impl.ParseOrderKind(*order)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Body, err = impl.ParseBodyKind(*body)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Delegate = *delegate
+	if *tmplFile != "" {
+		bts, err := ioutil.ReadFile(*tmplFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read template: %w", err)
+		}
+		cfg.Template = string(bts)
+	}
+	if *overlay != "" {
+		cfg.Overlay, err = readOverlay(*overlay)
+		if err != nil {
+			return nil, fmt.Errorf("could not read overlay: %w", err)
+		}
+	}
+	return cfg, nil
+}
+
+// readOverlay reads the contents of unsaved files from the given file,
+// or stdin if it's "-". The overlay is either a go build -overlay JSON
+// file, which maps file paths to replacement files on disk, or a txtar
+// archive holding the contents of each file.
+func readOverlay(name string) (map[string][]byte, error) {
+	var bts []byte
+	var err error
+	if name == "-" {
+		bts, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		bts, err = ioutil.ReadFile(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	overlay := map[string][]byte{}
+	if bytes.HasPrefix(bytes.TrimSpace(bts), []byte("{")) {
+		var resp struct {
+			Replace map[string]string
+		}
+		if err := json.Unmarshal(bts, &resp); err != nil {
+			return nil, fmt.Errorf("json decode err: %v", err)
+		}
+		for file, replacement := range resp.Replace {
+			if replacement == "" {
+				// the file is deleted
+				overlay[file] = nil
+				continue
+			}
+			content, err := ioutil.ReadFile(replacement)
+			if err != nil {
+				return nil, err
+			}
+			overlay[file] = content
+		}
+		return overlay, nil
+	}
+	for _, f := range txtar.Parse(bts).Files {
+		overlay[f.Name] = f.Data
+	}
+	return overlay, nil
+}
+
+// splitTypePath splits path.to/my/pkg.Name into its import path
+// and type name. Type arguments such as path.to/my/pkg.Name[K, V] stay
+// attached to the type name since they may contain dots themselves.
+func splitTypePath(arg string) (string, string, error) {
+	base, typeArgs := arg, ""
+	if idx := strings.Index(arg, "["); idx != -1 {
+		base, typeArgs = arg[:idx], arg[idx:]
+	}
+	idx := strings.LastIndex(base, ".")
+	if idx == -1 {
+		return "", "", fmt.Errorf("expected path.to/my/pkg.Type but got %q", arg)
+	}
+	return base[:idx], base[idx+1:] + typeArgs, nil
+}
+
+// positionArg matches file positions such as ./store/db.go:42:7
+var positionArg = regexp.MustCompile(`^(.+\.go):(\d+):(\d+)$`)
+
+// splitImplArg is like splitTypePath but also accepts the
+// position of the type declaration such as ./store/db.go:42:7
+func splitImplArg(cfg *impl.Config, arg string) (string, string, error) {
+	m := positionArg.FindStringSubmatch(arg)
+	if m == nil {
+		return splitTypePath(arg)
+	}
+	line, _ := strconv.Atoi(m[2])
+	col, _ := strconv.Atoi(m[3])
+	return impl.TypeAt(cfg, m[1], line, col)
+}
+
 func getPath() (string, error) {
 	if *path != "" {
 		return *path, nil
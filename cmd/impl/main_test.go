@@ -0,0 +1,30 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadOverlay(t *testing.T) {
+	dir := t.TempDir()
+	replacement := filepath.Join(dir, "replacement.go")
+	if err := ioutil.WriteFile(replacement, []byte("package store\n"), 0660); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(dir, "overlay.json")
+	js := `{"Replace": {"store/db.go": "` + filepath.ToSlash(replacement) + `", "store/old.go": ""}}`
+	if err := ioutil.WriteFile(name, []byte(js), 0660); err != nil {
+		t.Fatal(err)
+	}
+	overlay, err := readOverlay(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(overlay["store/db.go"]); got != "package store\n" {
+		t.Fatalf("expected the replacement content but got %q", got)
+	}
+	if content, ok := overlay["store/old.go"]; !ok || content != nil {
+		t.Fatalf("expected store/old.go to be deleted but got %q", content)
+	}
+}
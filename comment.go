@@ -0,0 +1,70 @@
+package impl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DocKind defines the doc comment of the generated methods
+type DocKind int
+
+// Doc kinds
+const (
+	// ImplementsDoc comments methods with "// Method implements Interface",
+	// naming the embedded interface that declares the method, if any.
+	ImplementsDoc DocKind = iota
+	// CopyDoc adds the doc comment of the interface method
+	// beneath the "implements" line.
+	CopyDoc
+	// ReplaceDoc uses the doc comment of the interface method instead of
+	// the "implements" line, unless the interface method has none.
+	ReplaceDoc
+)
+
+func (dk DocKind) String() string {
+	switch dk {
+	case ImplementsDoc:
+		return "implements"
+	case CopyDoc:
+		return "copy"
+	case ReplaceDoc:
+		return "replace"
+	}
+	return fmt.Sprintf("DocKind(%d)", int(dk))
+}
+
+// ParseDocKind parses "implements", "copy" or "replace" into a DocKind
+func ParseDocKind(s string) (DocKind, error) {
+	for _, dk := range []DocKind{ImplementsDoc, CopyDoc, ReplaceDoc} {
+		if strings.EqualFold(s, dk.String()) {
+			return dk, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown doc kind %q, expected implements, copy or replace", s)
+}
+
+// comment returns the doc comment of a generated method
+// according to the configured doc kind.
+func comment(cfg *Config, md *MethodData) string {
+	kind := ImplementsDoc
+	if cfg != nil {
+		kind = cfg.Doc
+	}
+	implements := "// " + md.Name + " implements " + md.Implements
+	if md.Doc == "" || kind == ImplementsDoc {
+		return implements
+	}
+	lines := strings.Split(strings.TrimSuffix(md.Doc, "\n"), "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = "//"
+		} else {
+			lines[i] = "// " + line
+		}
+	}
+	doc := strings.Join(lines, "\n")
+	if kind == ReplaceDoc {
+		return doc
+	}
+	return implements + "\n//\n" + doc
+}
@@ -0,0 +1,134 @@
+package impl
+
+import (
+	"context"
+	"io/fs"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Config defines the options used to implement an interface.
+// The zero value is ready to use and behaves like Implement.
+type Config struct {
+	// Context cancels loading packages, defaults to context.Background()
+	Context context.Context
+	// Dir is the directory in which to run the go command
+	// that loads packages, defaults to the current directory
+	Dir string
+	// Env is the environment used when loading packages,
+	// defaults to the current environment
+	Env []string
+	// Tags are the build tags to apply when loading packages
+	Tags []string
+	// Overlay maps file paths to their contents, such as unsaved editor
+	// buffers, which take precedence over the files on disk both when
+	// loading packages and when inserting the generated methods.
+	// Relative paths are resolved against Dir, and a nil content
+	// deletes the file like an empty go build -overlay replacement.
+	Overlay map[string][]byte
+	// Receiver is the kind of receiver of the generated
+	// methods, defaults to PointerReceiver
+	Receiver ReceiverKind
+	// ReceiverName is the receiver name of the generated methods when the
+	// type has no existing methods with a named receiver to copy it from.
+	// It defaults to the lowercased first letter of the type name,
+	// and "_" generates anonymous receivers.
+	ReceiverName string
+	// Doc comment of the generated methods, which defaults to ImplementsDoc.
+	Doc DocKind
+	// Chain attributes methods to the chain of embedded interfaces that
+	// declares them, such as "Partier > io.ReadCloser > io.Reader", instead
+	// of only the interface that declares them, such as "io.Reader".
+	Chain bool
+	// Output is the file in the concrete type's package that the methods
+	// are written to instead of the file declaring the concrete type.
+	// A file name without a directory is relative to the package
+	// directory, and the file is created if it does not exist.
+	Output string
+	// New declares the concrete type as an empty struct if it does not
+	// exist, in Output or else in a new file named after the type, such
+	// as "mystore.go" for MyStore, instead of failing to find it.
+	New bool
+	// Constructor adds a function such as NewMyStore, or newMyStore
+	// for unexported types, to the concrete type that New declares.
+	Constructor bool
+	// Placement of the generated methods, which defaults to AfterTypePlacement.
+	Placement PlacementKind
+	// Order of the generated methods, which defaults to EmbeddedFirstOrder.
+	Order OrderKind
+	// Body of the generated methods, which defaults to PanicBody.
+	Body BodyKind
+	// Methods restricts the generated methods to the given method names
+	// of the interface, and Exclude leaves out the given ones.
+	Methods, Exclude []string
+	// Delegate is the name of a field of the concrete type that the generated
+	// methods forward their calls to, such as "next" for
+	// return t.next.Method(args...), instead of using Body.
+	Delegate string
+	// Template is the text/template used to generate each method,
+	// which is executed with a *MethodData. Defaults to DefaultTemplate.
+	Template string
+}
+
+// packagesConfig returns the packages.Config used to load packages
+// in the given mode. It is safe to call on a nil Config.
+func (cfg *Config) packagesConfig(mode packages.LoadMode) *packages.Config {
+	pcfg := &packages.Config{Mode: mode}
+	if cfg == nil {
+		return pcfg
+	}
+	pcfg.Context = cfg.Context
+	pcfg.Dir = cfg.Dir
+	pcfg.Env = cfg.Env
+	if len(cfg.Tags) > 0 {
+		pcfg.BuildFlags = []string{"-tags=" + strings.Join(cfg.Tags, ",")}
+	}
+	if len(cfg.Overlay) > 0 {
+		pcfg.Overlay = map[string][]byte{}
+		for name, content := range cfg.Overlay {
+			if content == nil && strings.HasSuffix(name, ".go") {
+				// go/packages cannot delete files, so
+				// leave them out of the build instead.
+				content = []byte(deletedGoFile)
+			}
+			pcfg.Overlay[cfg.abs(name)] = content
+		}
+	}
+	return pcfg
+}
+
+// deletedGoFile replaces the Go files that the overlay deletes
+const deletedGoFile = "//go:build ignore\n\npackage ignore\n"
+
+// readFile returns the content of the given file from
+// the overlay if it has one, or from disk otherwise.
+// It is safe to call on a nil Config.
+func (cfg *Config) readFile(name string) ([]byte, error) {
+	if cfg != nil {
+		for overlayName, content := range cfg.Overlay {
+			if cfg.abs(overlayName) != cfg.abs(name) {
+				continue
+			}
+			if content == nil {
+				return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+			}
+			return content, nil
+		}
+	}
+	return ioutil.ReadFile(name)
+}
+
+// abs returns the absolute path of name relative to cfg.Dir.
+// It is safe to call on a nil Config.
+func (cfg *Config) abs(name string) string {
+	if !filepath.IsAbs(name) && cfg != nil && cfg.Dir != "" {
+		name = filepath.Join(cfg.Dir, name)
+	}
+	if abs, err := filepath.Abs(name); err == nil {
+		return abs
+	}
+	return filepath.Clean(name)
+}
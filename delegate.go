@@ -0,0 +1,108 @@
+package impl
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strings"
+)
+
+// delegateField returns the field of the concrete type
+// that the generated methods forward their calls to.
+func (tt *target) delegateField(name string) (*types.Var, error) {
+	obj, _, _ := types.LookupFieldOrMethod(tt.implObj.Type(), true, tt.ct.pkg, name)
+	field, ok := obj.(*types.Var)
+	if !ok || !field.IsField() {
+		return nil, fmt.Errorf("%v has no field %q to delegate to", tt.impl, name)
+	}
+	return field, nil
+}
+
+// checkDelegate verifies that the type of field has a method that can be
+// called with the parameters of m and whose results m can return.
+func (tt *target) checkDelegate(field *types.Var, m *types.Func) error {
+	obj, _, _ := types.LookupFieldOrMethod(field.Type(), true, tt.ct.pkg, m.Name())
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		typ := types.TypeString(field.Type(), types.RelativeTo(tt.ct.pkg))
+		return fmt.Errorf("cannot delegate %s: field %s of type %s has no method %s", m.Name(), field.Name(), typ, m.Name())
+	}
+	want := m.Type().(*types.Signature)
+	have := fn.Type().(*types.Signature)
+	if !canDelegate(want, have) {
+		return fmt.Errorf("cannot delegate %s to field %s:\nhave: %s\nwant: %s", m.Name(), field.Name(), have, want)
+	}
+	return nil
+}
+
+// canDelegate reports whether a method with the signature want
+// can be implemented by returning a call to a method with the signature have.
+func canDelegate(want, have *types.Signature) bool {
+	if want.Variadic() != have.Variadic() {
+		return false
+	}
+	if want.Params().Len() != have.Params().Len() || want.Results().Len() != have.Results().Len() {
+		return false
+	}
+	for i := 0; i < want.Params().Len(); i++ {
+		if !types.AssignableTo(want.Params().At(i).Type(), have.Params().At(i).Type()) {
+			return false
+		}
+	}
+	for i := 0; i < want.Results().Len(); i++ {
+		if !types.AssignableTo(have.Results().At(i).Type(), want.Results().At(i).Type()) {
+			return false
+		}
+	}
+	return true
+}
+
+// nameParams names the unnamed or blank parameters of ft, such as p0 and p1,
+// so that they can be passed along. Names in taken, such as the receiver's, are avoided.
+func nameParams(ft *ast.FuncType, taken ...string) {
+	if ft.Params == nil {
+		return
+	}
+	vars := []Var{}
+	for _, f := range ft.Params.List {
+		if len(f.Names) == 0 {
+			vars = append(vars, Var{})
+		}
+		for _, name := range f.Names {
+			vars = append(vars, Var{Name: name.Name})
+		}
+	}
+	for _, name := range taken {
+		vars = append(vars, Var{Name: name})
+	}
+	named := paramNames(vars)
+	i := 0
+	for _, f := range ft.Params.List {
+		if len(f.Names) == 0 {
+			f.Names = []*ast.Ident{ast.NewIdent(named[i].Name)}
+			i++
+			continue
+		}
+		for _, name := range f.Names {
+			name.Name = named[i].Name
+			i++
+		}
+	}
+}
+
+// delegateCall returns the statement that forwards
+// the call to md's method to the given field.
+func delegateCall(md *MethodData, field string) string {
+	args := make([]string, len(md.Params))
+	for i, p := range md.Params {
+		args[i] = p.Name
+	}
+	if md.Variadic {
+		args[len(args)-1] += "..."
+	}
+	call := fmt.Sprintf("%s.%s.%s(%s)", md.ReceiverName, field, md.Name, strings.Join(args, ", "))
+	if len(md.Results) == 0 {
+		return call
+	}
+	return "return " + call
+}
@@ -0,0 +1,126 @@
+package impl
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// TextEdit is a change to the original content of a file: NewText replaces
+// everything between the start and end of Range, and an insertion has an empty
+// Range. It is compatible with the TextEdit of the Language Server Protocol.
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+// Range is a span of a file between two positions
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Position is a location in a file. Line and Character are zero based
+// and Character counts UTF-16 code units like the Language Server Protocol.
+// Offset is the zero based byte offset of the same location.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+	Offset    int `json:"offset"`
+}
+
+// computeEdits returns the minimal edits that add the generated methods and
+// imports to src without reformatting anything else, sorted by their offset.
+func computeEdits(filename string, src []byte, inserts []*insertion, imports []*AddedImport) ([]TextEdit, error) {
+	edits := []edit{}
+	for _, ins := range inserts {
+		edits = append(edits, edit{ins.offset, ins.offset, "\n\n" + string(bytes.TrimRight(ins.methods, "\n"))})
+	}
+	if len(imports) > 0 {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, filename, src, parser.ImportsOnly)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %v: %w", filename, err)
+		}
+		edits = append(edits, importEdit(fset, f, src, imports))
+	}
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].start < edits[j].start
+	})
+	textEdits := []TextEdit{}
+	for _, e := range edits {
+		textEdits = append(textEdits, TextEdit{
+			Range:   Range{offsetPosition(src, e.start), offsetPosition(src, e.end)},
+			NewText: e.text,
+		})
+	}
+	return textEdits, nil
+}
+
+// edit replaces the bytes between the start and end offsets with text
+type edit struct {
+	start, end int
+	text       string
+}
+
+// importEdit returns the edit that makes f import all of the given imports.
+// The imports are appended to the last parenthesized import declaration,
+// a single import declaration is turned into a parenthesized one, and
+// otherwise a new declaration is added after the package clause.
+func importEdit(fset *token.FileSet, f *ast.File, src []byte, imports []*AddedImport) edit {
+	var specs bytes.Buffer
+	for _, imp := range imports {
+		specs.WriteByte('\t')
+		if imp.Name != "" {
+			specs.WriteString(imp.Name + " ")
+		}
+		specs.WriteString(strconv.Quote(imp.Path) + "\n")
+	}
+	var lastImport *ast.GenDecl
+	for _, decl := range f.Decls {
+		if gd, ok := decl.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+			lastImport = gd
+		}
+	}
+	switch {
+	case lastImport != nil && lastImport.Lparen.IsValid():
+		offset := fset.Position(lastImport.Rparen).Offset
+		lineStart := bytes.LastIndexByte(src[:offset], '\n') + 1
+		if len(bytes.TrimSpace(src[lineStart:offset])) == 0 {
+			return edit{lineStart, lineStart, specs.String()}
+		}
+		return edit{offset, offset, "\n" + specs.String()}
+	case lastImport != nil:
+		spec := lastImport.Specs[0]
+		start := fset.Position(lastImport.Pos()).Offset
+		end := fset.Position(lastImport.End()).Offset
+		specStart := fset.Position(spec.Pos()).Offset
+		specEnd := fset.Position(spec.End()).Offset
+		return edit{start, end, "import (\n\t" + string(src[specStart:specEnd]) + "\n" + specs.String() + ")"}
+	case len(imports) == 1:
+		offset := fset.Position(f.Name.End()).Offset
+		return edit{offset, offset, "\n\nimport " + string(bytes.TrimSpace(specs.Bytes()))}
+	}
+	offset := fset.Position(f.Name.End()).Offset
+	return edit{offset, offset, "\n\nimport (\n" + specs.String() + ")"}
+}
+
+// offsetPosition converts a byte offset of src into a Position
+func offsetPosition(src []byte, offset int) Position {
+	pos := Position{Offset: offset}
+	lineStart := bytes.LastIndexByte(src[:offset], '\n') + 1
+	pos.Line = bytes.Count(src[:lineStart], []byte("\n"))
+	for _, r := range string(src[lineStart:offset]) {
+		if utf8.RuneLen(r) == 4 {
+			pos.Character += 2
+		} else {
+			pos.Character++
+		}
+	}
+	return pos
+}
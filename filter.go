@@ -0,0 +1,58 @@
+package impl
+
+import (
+	"fmt"
+	"go/types"
+	"strings"
+)
+
+// skipFilteredMethods marks the methods of the interface that Config.Methods
+// and Config.Exclude leave out as visited so that they are not generated.
+// Their signatures are kept so that later interfaces can still conflict with them.
+func skipFilteredMethods(cfg *Config, ifaceType *types.Named, visited *visitedMethods) {
+	iface, ok := ifaceType.Underlying().(*types.Interface)
+	if !ok {
+		// reported by missingMethods
+		return
+	}
+	visited.ifaces = append(visited.ifaces, ifaceType.Obj().Name())
+	for i := 0; i < iface.NumMethods(); i++ {
+		visited.names[iface.Method(i).Name()] = true
+	}
+	if cfg == nil || (len(cfg.Methods) == 0 && len(cfg.Exclude) == 0) {
+		return
+	}
+	only := map[string]bool{}
+	for _, name := range cfg.Methods {
+		only[name] = true
+	}
+	exclude := map[string]bool{}
+	for _, name := range cfg.Exclude {
+		exclude[name] = true
+	}
+	for i := 0; i < iface.NumMethods(); i++ {
+		method := iface.Method(i)
+		name := method.Name()
+		if _, ok := visited.methods[name]; ok {
+			continue
+		}
+		if (len(only) > 0 && !only[name]) || exclude[name] {
+			visited.methods[name] = visitedMethod{iface: qualifiedName(ifaceType), fn: method}
+		}
+	}
+}
+
+// checkFilter returns an error if Config.Methods or Config.Exclude name a method
+// that is not in the method set of any of the visited interfaces, which
+// includes the methods of their embedded interfaces.
+func (v *visitedMethods) checkFilter(cfg *Config) error {
+	if cfg == nil {
+		return nil
+	}
+	for _, name := range append(append([]string{}, cfg.Methods...), cfg.Exclude...) {
+		if !v.names[name] {
+			return fmt.Errorf("%s has no method %q", strings.Join(v.ifaces, ", "), name)
+		}
+	}
+	return nil
+}
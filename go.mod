@@ -1,8 +1,16 @@
 module marwan.io/impl
 
-go 1.14
+go 1.22.0
 
 require (
 	github.com/stretchr/testify v1.5.1
-	golang.org/x/tools v0.0.0-20200221224223-e1da425f72fd
+	golang.org/x/tools v0.30.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/mod v0.23.0 // indirect
+	golang.org/x/sync v0.11.0 // indirect
+	gopkg.in/yaml.v2 v2.2.2 // indirect
 )
@@ -10,10 +10,10 @@ import (
 	"go/parser"
 	"go/token"
 	"go/types"
-	"io/ioutil"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
-	"text/template"
 
 	"golang.org/x/tools/go/ast/astutil"
 	"golang.org/x/tools/go/packages"
@@ -22,9 +22,12 @@ import (
 // Implementation defines the results of
 // the implement method
 type Implementation struct {
-	File         string         // path to the Go file of the implementing type
+	File         string         // path to the Go file of the implementing type, or Config.Output
+	NewFile      bool           // whether File does not exist yet, in which case Edits insert all of FileContent
+	Original     []byte         // the Go file before the methods were added, taking Config.Overlay into account, nil if NewFile
 	FileContent  []byte         // the Go file plus the method implementations at the bottom of the file
 	Methods      []byte         // only the method implementations, helpful if you want to insert the methods elsewhere in the file
+	Edits        []TextEdit     // minimal edits to the original file that add the methods and imports, without reformatting it
 	AddedImports []*AddedImport // all the required imports for the methods, it does not filter out imports already imported by the file
 	AllImports   []*AddedImport // convenience to get a list of all the imports of the concrete type file
 	Error        error          // any error encountered during the process
@@ -39,8 +42,84 @@ type AddedImport struct {
 
 // Implement an interface and return the path to as well as the content of the
 // file where the concrete type was defined updated with all of the missing methods
+//
+// The interface may be generic, in which case iface must carry its type arguments
+// such as "Store[string, *models.User]". Type arguments are resolved against the imports
+// of the concrete type and interface files, or can be qualified by their full import path
+// such as "Store[string, *example.com/models.User]".
 func Implement(ifacePath, iface, implPath, impl string) (*Implementation, error) {
-	ifacePkg, implPkg, err := loadPackages(ifacePath, implPath)
+	return ImplementWithConfig(nil, ifacePath, iface, implPath, impl)
+}
+
+// ImplementWithConfig is like Implement but lets the caller customize
+// how packages are loaded and how methods are generated. A nil cfg
+// is the same as calling Implement.
+func ImplementWithConfig(cfg *Config, ifacePath, iface, implPath, impl string) (*Implementation, error) {
+	load := func(ifacePath, implPath string, extra ...string) (*packages.Package, *packages.Package, []*packages.Package, error) {
+		return loadPackages(cfg, ifacePath, implPath, extra...)
+	}
+	return implement(cfg, load, ifacePath, iface, implPath, impl)
+}
+
+// loadFunc loads the interface and implementation packages along with any
+// extra packages and returns them as well as all of the loaded root packages.
+type loadFunc func(ifacePath, implPath string, extra ...string) (ifacePkg, implPkg *packages.Package, roots []*packages.Package, err error)
+
+// target is an interface and a concrete type that have
+// been loaded and compared to find the missing methods.
+type target struct {
+	iface        string
+	ifacePath    string
+	impl         string
+	implPath     string
+	implPkg      *packages.Package
+	implObj      types.Object
+	implFilename string               // file the methods are written to
+	fset         *token.FileSet       // file set of implFilename
+	newFile      []byte               // content of implFilename if it does not exist yet
+	declFile     bool                 // whether implFilename declares the concrete type
+	declare      bool                 // whether the concrete type does not exist and is declared with the methods
+	ifaceNames   []string             // names of the interfaces relative to the concrete type
+	tparams      *types.TypeParamList // type parameters of a generic concrete type
+	ct           *concreteType
+	missing      []*missingInterface
+
+	// hasErrNotImplemented reports whether the concrete type's package declares
+	// ErrNotImplemented, which ErrorBody methods set needsErrNotImplemented to declare.
+	hasErrNotImplemented   bool
+	needsErrNotImplemented bool
+}
+
+// visitedMethods records the methods that the interfaces implemented
+// onto a concrete type so far have generated or filtered out.
+type visitedMethods struct {
+	methods map[string]visitedMethod
+	names   map[string]bool // all method names of the visited interfaces
+	ifaces  []string        // names of the visited interfaces
+}
+
+// visitedMethod is a method generated for the interface that declares it,
+// or filtered out of it by the configuration.
+type visitedMethod struct {
+	iface string
+	fn    *types.Func
+}
+
+func newVisitedMethods() *visitedMethods {
+	return &visitedMethods{methods: map[string]visitedMethod{}, names: map[string]bool{}}
+}
+
+// prepare loads the interface and the concrete type and
+// determines which interface methods the concrete type is missing.
+// Methods already in visited are skipped, which lets several interfaces share methods,
+// and so are the ones filtered out by the configuration.
+func prepare(cfg *Config, load loadFunc, ifacePath, iface, implPath, impl string, visited *visitedMethods) (*target, error) {
+	spec, err := parseTypeSpec(iface)
+	if err != nil {
+		return nil, err
+	}
+	iface = spec.name
+	ifacePkg, implPkg, roots, err := load(ifacePath, implPath, spec.importPaths()...)
 	if err != nil {
 		return nil, err
 	}
@@ -49,83 +128,252 @@ func Implement(ifacePath, iface, implPath, impl string) (*Implementation, error)
 		return nil, fmt.Errorf("could not find interface declaration (%s) in %s", iface, ifacePath)
 	}
 	implObj := implPkg.Types.Scope().Lookup(impl)
-	if implObj == nil {
+	declare := implObj == nil && cfg != nil && cfg.New
+	if declare {
+		implObj, err = newConcreteType(implPkg.Types, impl)
+		if err != nil {
+			return nil, err
+		}
+	} else if implObj == nil {
 		return nil, fmt.Errorf("could not find type declaration (%s) in %s", impl, implPath)
 	}
-	implFilename, implFileAST := getFile(implPkg, implObj)
+	var implFilename string
+	var implFileAST *ast.File
+	if !declare {
+		implFilename, implFileAST = getFile(implPkg, implObj)
+	}
+	_, ifaceFileAST := getFile(ifacePkg, ifaceObj)
+	resolver := newTypeResolver(spec, []*packages.Package{implPkg, ifacePkg}, roots, []*ast.File{implFileAST, ifaceFileAST})
+	resolver.tparams = typeParams(implObj.Type())
+	ifaceType, err := instantiateInterface(ifaceObj, resolver)
+	if err != nil {
+		return nil, err
+	}
+	skipFilteredMethods(cfg, ifaceType, visited)
 	ct := &concreteType{
 		pkg:  implPkg.Types,
-		fset: implPkg.Fset,
+		pkgs: resolver.pkgs,
 		file: implFileAST,
 		tms:  types.NewMethodSet(implObj.Type()),
 		pms:  types.NewMethodSet(types.NewPointer(implObj.Type())),
 	}
-	missing, err := missingMethods(ct, ifaceObj, ifacePkg, map[string]struct{}{})
+	fset := implPkg.Fset
+	var newFile []byte
+	if output := outputName(cfg, impl, declare); output != "" {
+		out, err := openOutput(cfg, implPkg, output)
+		if err != nil {
+			return nil, err
+		}
+		// imports are looked up in the output file instead
+		implFilename, ct.file, fset, newFile = out.name, out.file, out.fset, out.src
+	}
+	missing, err := missingMethods(ct, ifaceType, ifacePkg, visited, nil, nil)
 	if err != nil {
 		return nil, err
 	}
-	if len(missing) == 0 {
+	return &target{
+		iface:        iface,
+		ifacePath:    ifacePath,
+		impl:         impl,
+		implPath:     implPath,
+		implPkg:      implPkg,
+		implObj:      implObj,
+		implFilename: implFilename,
+		fset:         fset,
+		newFile:      newFile,
+		declFile:     ct.file == implFileAST,
+		declare:      declare,
+		ifaceNames:   []string{ct.relativeName(ifaceType)},
+		tparams:      resolver.tparams,
+		ct:           ct,
+		missing:      missing,
+
+		hasErrNotImplemented: implPkg.Types.Scope().Lookup(errNotImplemented) != nil,
+	}, nil
+}
+
+func implement(cfg *Config, load loadFunc, ifacePath, iface, implPath, impl string) (*Implementation, error) {
+	visited := newVisitedMethods()
+	tt, err := prepare(cfg, load, ifacePath, iface, implPath, impl, visited)
+	if err != nil {
+		return nil, err
+	}
+	if err := visited.checkFilter(cfg); err != nil {
+		return nil, err
+	}
+	if len(tt.missing) == 0 && !tt.declare {
 		return nil, nil
 	}
+	ins, err := tt.generate(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return render(cfg, tt.implFilename, []*insertion{ins})
+}
+
+// insertion is a set of generated methods along with the
+// imports they require, to be inserted at an offset of a file.
+type insertion struct {
+	offset  int
+	methods []byte
+	imports []*AddedImport
+	newFile []byte // content of the file if it does not exist yet
+}
+
+// generate writes the missing methods of the target and returns them
+// along with the offset of the concrete type file where they belong.
+func (tt *target) generate(cfg *Config) (*insertion, error) {
+	ct := tt.ct
+	receiver := receiverType(tt.impl, tt.tparams)
+	if tt.receiverKind(cfg) == PointerReceiver {
+		receiver = "*" + receiver
+	}
+	recvName := tt.receiverName(cfg)
+	t, err := methodTemplate(cfg, ct.templateFuncs())
+	if err != nil {
+		return nil, err
+	}
+	var delegate *types.Var
+	if cfg != nil && cfg.Delegate != "" {
+		delegate, err = tt.delegateField(cfg.Delegate)
+		if err != nil {
+			return nil, err
+		}
+	}
 	var methodsBuffer bytes.Buffer
-	for _, mm := range missing {
-		// imports := getInterfaceImports(mm.missing)
-		// allImports = append(allImports, imports...)
-		// addPathsToImplFile(implPkg.Fset, implFileAST, implPath, imports)
-		t := template.Must(template.New("").Parse(tmpl))
-		for _, m := range mm.missing {
-			var sig bytes.Buffer
-
-			nn, _ := astutil.PathEnclosingInterval(mm.file, m.Pos(), m.Pos())
-			var n ast.Node = nn[1].(*ast.Field).Type
-			n = astutil.Apply(n, func(c *astutil.Cursor) bool {
-				sel, ok := c.Node().(*ast.SelectorExpr)
-				if ok {
-					renamed := mightRenameSelector(c, sel, ifacePkg, ct)
-					removed := mightRemoveSelector(c, sel, ifacePkg, implPath)
-					return removed || renamed
-				}
-				ident, ok := c.Node().(*ast.Ident)
-				if ok {
-					return mightAddSelector(c, ident, ifacePkg, ct)
-				}
-				return true
-			}, nil)
-			err = format.Node(&sig, ifacePkg.Fset, n)
-			if err != nil {
-				return nil, fmt.Errorf("could not format function signature: %w", err)
+	if tt.declare {
+		methodsBuffer.WriteString(tt.typeDecl(cfg, receiver))
+	}
+	stubs := tt.stubs(cfg)
+	for i, s := range stubs {
+		mm, m := s.mm, s.fn
+		methodsBuffer.WriteString(groupComment(cfg, stubs, i))
+		if delegate != nil {
+			if err := tt.checkDelegate(delegate, m); err != nil {
+				return nil, err
 			}
-			md := methodData{
-				Name:        m.Name(),
-				Implementer: impl,
-				Interface:   iface,
-				Signature:   strings.TrimPrefix(sig.String(), "func"),
+		}
+		mSig := m.Type().(*types.Signature)
+		mRecvName := uniqueName(recvName, mSig)
+		var sig bytes.Buffer
+
+		nn, _ := astutil.PathEnclosingInterval(mm.file, m.Pos(), m.Pos())
+		field := nn[1].(*ast.Field)
+		// the interface syntax might be shared across calls,
+		// so rewrite a copy of it instead of the original.
+		n, uses := cloneExpr(field.Type, mm.pkg.TypesInfo)
+		n = astutil.Apply(n, func(c *astutil.Cursor) bool {
+			sel, ok := c.Node().(*ast.SelectorExpr)
+			if ok {
+				renamed := mightRenameSelector(c, sel, uses, ct)
+				removed := mightRemoveSelector(c, sel, uses, tt.implPath)
+				return removed || renamed
 			}
-			err = t.Execute(&methodsBuffer, md)
-			if err != nil {
-				return nil, fmt.Errorf("error executing method template: %w", err)
+			ident, ok := c.Node().(*ast.Ident)
+			if ok {
+				if replaced := mightReplaceTypeParam(c, ident, uses, mm, ct); replaced {
+					return false
+				}
+				return mightAddSelector(c, ident, uses, mm.pkg, ct)
 			}
-			methodsBuffer.WriteRune('\n')
+			return true
+		}, nil).(ast.Expr)
+		if delegate != nil {
+			// every parameter is passed along so they all need a name
+			nameParams(n.(*ast.FuncType), mRecvName)
+		}
+		err := format.Node(&sig, mm.pkg.Fset, n)
+		if err != nil {
+			return nil, fmt.Errorf("could not format function signature: %w", err)
+		}
+		md := &MethodData{
+			Name:            m.Name(),
+			Doc:             field.Doc.Text(),
+			Interface:       tt.iface,
+			InterfacePath:   tt.ifacePath,
+			Origin:          mm.name,
+			OriginPath:      mm.pkg.PkgPath,
+			Implementer:     tt.impl,
+			ReceiverName:    mRecvName,
+			ReceiverType:    receiver,
+			PointerReceiver: strings.HasPrefix(receiver, "*"),
+			Signature:       strings.TrimPrefix(sig.String(), "func"),
+			Variadic:        mSig.Variadic(),
+			ReturnsError:    returnsError(mSig),
+		}
+		md.Receiver = strings.TrimSpace(md.ReceiverName + " " + receiver)
+		md.Chain = mm.chain
+		md.Implements = mm.chain[len(mm.chain)-1]
+		if cfg != nil && cfg.Chain {
+			md.Implements = strings.Join(mm.chain, " > ")
+		}
+		md.Comment = comment(cfg, md)
+		md.body = func() (string, error) { return tt.body(cfg, md) }
+		md.Params, md.Results, err = signatureVars(n.(*ast.FuncType), mSig, mm.pkg.Fset)
+		if err != nil {
+			return nil, err
+		}
+		err = t.Execute(&methodsBuffer, md)
+		if err != nil {
+			return nil, fmt.Errorf("error executing method template: %w", err)
 		}
+		methodsBuffer.WriteRune('\n')
+	}
+	if tt.needsErrNotImplemented {
+		methodsBuffer.WriteString(tt.errNotImplementedDecl())
+		methodsBuffer.WriteRune('\n')
 	}
-	nodes, _ := astutil.PathEnclosingInterval(implFileAST, implObj.Pos(), implObj.Pos())
-	insertPos := implPkg.Fset.Position(nodes[1].End()).Offset
-	implFileBts, err := ioutil.ReadFile(implFilename)
+	pos, err := tt.insertPos(cfg)
 	if err != nil {
 		return nil, err
 	}
-	var buf bytes.Buffer
-	buf.Write(implFileBts[:insertPos])
-	buf.WriteByte('\n')
-	buf.Write(methodsBuffer.Bytes())
-	buf.Write(implFileBts[insertPos:])
+	return &insertion{
+		offset:  tt.fset.Position(pos).Offset,
+		newFile: tt.newFile,
+		methods: methodsBuffer.Bytes(),
+		imports: ct.addedImports,
+	}, nil
+}
+
+// render inserts the generated methods into the given file
+// and adds their imports, returning the resulting implementation.
+func render(cfg *Config, filename string, inserts []*insertion) (*Implementation, error) {
+	implFileBts, newFile := inserts[0].newFile, inserts[0].newFile != nil
+	if !newFile {
+		var err error
+		implFileBts, err = cfg.readFile(filename)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(inserts, func(i, j int) bool {
+		return inserts[i].offset < inserts[j].offset
+	})
+	var buf, methodsBuffer bytes.Buffer
+	addedImports := []*AddedImport{}
+	last := 0
+	for _, ins := range inserts {
+		buf.Write(implFileBts[last:ins.offset])
+		// a blank line keeps the methods apart from a
+		// preceding comment, such as the insert marker.
+		buf.WriteString("\n\n")
+		buf.Write(ins.methods)
+		methodsBuffer.Write(ins.methods)
+		last = ins.offset
+		for _, imp := range ins.imports {
+			if !hasImport(addedImports, imp) {
+				addedImports = append(addedImports, imp)
+			}
+		}
+	}
+	buf.Write(implFileBts[last:])
 	fset := token.NewFileSet()
 
-	newF, err := parser.ParseFile(fset, implFilename, buf.Bytes(), parser.ParseComments)
+	newF, err := parser.ParseFile(fset, filename, buf.Bytes(), parser.ParseComments)
 	if err != nil {
 		return nil, fmt.Errorf("could not reparse file: %w", err)
 	}
-	for _, imp := range ct.addedImports {
+	for _, imp := range addedImports {
 		astutil.AddNamedImport(fset, newF, imp.Name, imp.Path)
 	}
 	var source bytes.Buffer
@@ -133,8 +381,20 @@ func Implement(ifacePath, iface, implPath, impl string) (*Implementation, error)
 	if err != nil {
 		return nil, err
 	}
+	var original []byte
+	if !newFile {
+		original = implFileBts
+	}
+	edits, err := computeEdits(filename, implFileBts, inserts, addedImports)
+	if err != nil {
+		return nil, err
+	}
+	if newFile {
+		// there is no original file to edit
+		edits = []TextEdit{{NewText: source.String()}}
+	}
 	allImports := []*AddedImport{}
-	for _, imp := range implFileAST.Imports {
+	for _, imp := range newF.Imports {
 		ai := &AddedImport{"", imp.Path.Value}
 		if imp.Name != nil {
 			ai.Name = imp.Name.Name
@@ -142,21 +402,45 @@ func Implement(ifacePath, iface, implPath, impl string) (*Implementation, error)
 		allImports = append(allImports, ai)
 	}
 	return &Implementation{
-		File:         implFilename,
+		File:         filename,
+		NewFile:      newFile,
+		Original:     original,
 		FileContent:  source.Bytes(),
 		Methods:      methodsBuffer.Bytes(),
+		Edits:        edits,
 		Error:        err,
-		AddedImports: ct.addedImports,
+		AddedImports: addedImports,
 		AllImports:   allImports,
 	}, err
 }
 
-// ListInterfaces ...
+func hasImport(imports []*AddedImport, imp *AddedImport) bool {
+	for _, i := range imports {
+		if i.Name == imp.Name && i.Path == imp.Path {
+			return true
+		}
+	}
+	return false
+}
+
+// ListInterfaces lists all interfaces that can be implemented
+// within the given path and its dependencies. Constraint interfaces
+// with type sets are skipped since they cannot be implemented.
 func ListInterfaces(path string) ([]string, error) {
-	pkgs, err := loadPackage(path)
+	return ListInterfacesWithConfig(nil, path)
+}
+
+// ListInterfacesWithConfig is like ListInterfaces but loads packages
+// according to cfg, such as with its build tags or overlay.
+func ListInterfacesWithConfig(cfg *Config, path string) ([]string, error) {
+	pkgs, err := loadPackage(cfg, path)
 	if err != nil {
 		return nil, err
 	}
+	return listAllInterfaces(pkgs)
+}
+
+func listAllInterfaces(pkgs []*packages.Package) ([]string, error) {
 	mp := make(map[string]struct{})
 	ifaces := []string{}
 	for _, pkg := range pkgs {
@@ -192,6 +476,9 @@ func listInterfaces(pkg *packages.Package, path string, visited map[string]struc
 				if _, ok = ts.Type.(*ast.InterfaceType); !ok {
 					continue
 				}
+				if isTypeSet(pkg, ts.Name.Name) {
+					continue
+				}
 				ifaces = append(ifaces, path+"."+ts.Name.Name)
 			}
 			return false
@@ -207,21 +494,34 @@ func listInterfaces(pkg *packages.Package, path string, visited map[string]struc
 	return ifaces, nil
 }
 
-func loadPackage(path string) ([]*packages.Package, error) {
-	var cfg packages.Config
-	cfg.Mode = packages.NeedName | packages.NeedImports | packages.NeedDeps | packages.NeedSyntax
-	pkgs, err := packages.Load(&cfg, path)
+func loadPackage(cfg *Config, path string) ([]*packages.Package, error) {
+	mode := packages.NeedName | packages.NeedImports | packages.NeedDeps | packages.NeedSyntax | packages.NeedTypes
+	pkgs, err := packages.Load(cfg.packagesConfig(mode), path)
 	if err != nil {
 		return nil, fmt.Errorf("error loading packages: %w", err)
 	}
 	return pkgs, nil
 }
 
+// isTypeSet reports whether the named interface declared in
+// pkg is a constraint interface that is not a plain method set.
+func isTypeSet(pkg *packages.Package, name string) bool {
+	if pkg.Types == nil {
+		return false
+	}
+	tn, ok := pkg.Types.Scope().Lookup(name).(*types.TypeName)
+	if !ok {
+		return false
+	}
+	iface, ok := tn.Type().Underlying().(*types.Interface)
+	return ok && !iface.IsMethodSet()
+}
+
 // mightRemoveSelector will replace a selector such as *models.User to just be *User.
 // This is needed if the interface method imports the same package where the concrete type
 // is going to implement that method
-func mightRemoveSelector(c *astutil.Cursor, sel *ast.SelectorExpr, ifacePkg *packages.Package, implPath string) bool {
-	obj := ifacePkg.TypesInfo.Uses[sel.Sel]
+func mightRemoveSelector(c *astutil.Cursor, sel *ast.SelectorExpr, uses map[*ast.Ident]types.Object, implPath string) bool {
+	obj := uses[sel.Sel]
 	if obj.Pkg().Path() == implPath {
 		c.Replace(sel.Sel)
 		return false
@@ -233,12 +533,12 @@ func mightRemoveSelector(c *astutil.Cursor, sel *ast.SelectorExpr, ifacePkg *pac
 // if the target conrete type file already imports the "models" package but has renamed it.
 // If the concrete type does not have the import file, then the import file will be added along with its
 // rename if the interface file has defined one.
-func mightRenameSelector(c *astutil.Cursor, sel *ast.SelectorExpr, ifacePkg *packages.Package, ct *concreteType) bool {
+func mightRenameSelector(c *astutil.Cursor, sel *ast.SelectorExpr, uses map[*ast.Ident]types.Object, ct *concreteType) bool {
 	ident, ok := sel.X.(*ast.Ident)
 	if !ok {
 		return false
 	}
-	obj := ifacePkg.TypesInfo.Uses[ident]
+	obj := uses[ident]
 	if obj == nil {
 		return false
 	}
@@ -247,36 +547,21 @@ func mightRenameSelector(c *astutil.Cursor, sel *ast.SelectorExpr, ifacePkg *pac
 		return false
 	}
 	pkg := pn.Imported()
-	var hasImport bool
-	var importName string
-	for _, imp := range ct.file.Imports {
-		impPath, _ := strconv.Unquote(imp.Path.Value)
-		if impPath == pkg.Path() && !isIgnoredImport(imp) {
-			hasImport = true
-			importName = pkg.Name()
-			if imp.Name != nil && imp.Name.Name != pkg.Name() {
-				importName = imp.Name.Name
-			}
-			break
-		}
-	}
-	if hasImport {
-		ident.Name = importName
-		c.Replace(sel)
-		return false
-	}
-	// there is no import, let's add a new one
 	if pkg.Path() == ct.pkg.Path() {
-		// but not if we're importing the path to the concrete type
-		// in which case we're dropping this in mightRemoveSelector
+		// we're dropping selectors of the concrete
+		// type's package in mightRemoveSelector
 		return false
 	}
-	// if we're adding a new import to the concrete type file, and
-	// it has been renamed in the interface file, honor the rename.
-	if pn.Name() != pkg.Name() {
-		importName = pn.Name()
+	importName, hasImport := ct.lookupImport(pkg)
+	if !hasImport && pn.Name() != pkg.Name() {
+		// if we're adding a new import to the concrete type file, and
+		// it has been renamed in the interface file, honor the rename.
+		importName = ct.importAs(types.NewPackage(pkg.Path(), pn.Name()), true)
+	} else if !hasImport {
+		importName = ct.importName(pkg)
 	}
-	ct.addImport(importName, pkg.Path())
+	ident.Name = importName
+	c.Replace(sel)
 	return false
 }
 
@@ -288,10 +573,11 @@ func mightRenameSelector(c *astutil.Cursor, sel *ast.SelectorExpr, ifacePkg *pac
 func mightAddSelector(
 	c *astutil.Cursor,
 	ident *ast.Ident,
+	uses map[*ast.Ident]types.Object,
 	ifacePkg *packages.Package,
 	ct *concreteType,
 ) bool {
-	obj := ifacePkg.TypesInfo.Uses[ident]
+	obj := uses[ident]
 	if obj == nil {
 		return false
 	}
@@ -303,21 +589,9 @@ func mightAddSelector(
 	if pkg == nil {
 		return false
 	}
-	pkgName := pkg.Name()
-	missingImport := true
-	for _, imp := range ct.file.Imports {
-		impPath, _ := strconv.Unquote(imp.Path.Value)
-		if pkg.Path() == impPath && !isIgnoredImport(imp) {
-			missingImport = false
-			if imp.Name != nil {
-				pkgName = imp.Name.Name
-			}
-			break
-		}
-	}
-	isNotImportingDestination := pkg.Path() != ct.pkg.Path()
-	if missingImport && isNotImportingDestination {
-		ct.addImport("", pkg.Path())
+	pkgName := ""
+	if pkg.Path() != ct.pkg.Path() {
+		pkgName = ct.importName(pkg)
 	}
 	isLocalDeclaration := pkg.Path() == ifacePkg.Types.Path() && pkg.Path() != ct.pkg.Path()
 	isDotImport := pkg.Path() != ifacePkg.Types.Path() && pkg.Path() != ct.pkg.Path()
@@ -333,40 +607,87 @@ func mightAddSelector(
 	return false
 }
 
-type methodData struct {
-	Name        string
-	Interface   string
-	Implementer string
-	Signature   string
+// mightReplaceTypeParam replaces a type parameter such as "K" in a generic interface
+// method with the type argument the interface was instantiated with, qualified
+// relative to the concrete type's file. It reports whether the identifier was replaced.
+func mightReplaceTypeParam(c *astutil.Cursor, ident *ast.Ident, uses map[*ast.Ident]types.Object, mm *missingInterface, ct *concreteType) bool {
+	if mm.subst == nil {
+		return false
+	}
+	tn, ok := uses[ident].(*types.TypeName)
+	if !ok {
+		return false
+	}
+	tp, ok := tn.Type().(*types.TypeParam)
+	if !ok {
+		return false
+	}
+	targ, ok := mm.subst[tp]
+	if !ok {
+		return false
+	}
+	// the type string is printed verbatim by go/format, which avoids
+	// mixing positions from a different file set into the signature.
+	c.Replace(ast.NewIdent(types.TypeString(targ, ct.qualifier)))
+	return true
 }
 
-const tmpl = `// {{ .Name }} implements {{ .Interface }}
-func (*{{ .Implementer }}) {{ .Name }}{{ .Signature }} {
-	panic("unimplemented")
-}
-`
+// loadMode is everything needed to implement an interface: the syntax and type
+// information of the interface, the concrete type and all of their dependencies.
+const loadMode = packages.NeedName | packages.NeedFiles | packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedImports | packages.NeedDeps
 
-func loadPackages(ifacePath, implPath string) (ifacePkg *packages.Package, implPkg *packages.Package, err error) {
-	var cfg packages.Config
-	cfg.Mode = packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedImports | packages.NeedDeps
-	pkgs, err := packages.Load(&cfg, ifacePath, implPath)
+// loadPackages loads the interface and implementation packages along with
+// any extra packages, such as the ones referenced by type arguments.
+// It also returns all of the loaded root packages.
+func loadPackages(cfg *Config, ifacePath, implPath string, extra ...string) (ifacePkg *packages.Package, implPkg *packages.Package, roots []*packages.Package, err error) {
+	pkgs, err := packages.Load(cfg.packagesConfig(loadMode), append([]string{ifacePath, implPath}, extra...)...)
 	if err != nil {
-		return nil, nil, fmt.Errorf("error loading packages: %w", err)
+		return nil, nil, nil, fmt.Errorf("error loading packages: %w", err)
 	}
 	for _, p := range pkgs {
 		pkgPath := p.Types.Path()
 		if pkgPath == ifacePath {
 			ifacePkg = p
-		} else if pkgPath == implPath {
+		}
+		if pkgPath == implPath {
 			implPkg = p
 		}
 	}
 	if ifacePkg == nil {
-		return nil, nil, fmt.Errorf("missing interface package info for %v", ifacePath)
+		return nil, nil, nil, fmt.Errorf("missing interface package info for %v", ifacePath)
 	} else if implPkg == nil {
-		return nil, nil, fmt.Errorf("missing implementation package info for %v", ifacePath)
+		return nil, nil, nil, fmt.Errorf("missing implementation package info for %v", implPath)
+	}
+	return ifacePkg, implPkg, pkgs, nil
+}
+
+// instantiateInterface returns the named interface type of ifaceObj. If the interface
+// is generic, it is instantiated with the type arguments given to the resolver.
+func instantiateInterface(ifaceObj types.Object, resolver *typeResolver) (*types.Named, error) {
+	named, ok := types.Unalias(ifaceObj.Type()).(*types.Named)
+	if !ok {
+		return nil, fmt.Errorf("expected %v to be a named type but got %T", ifaceObj.Name(), ifaceObj.Type())
+	}
+	tparams := named.TypeParams().Len()
+	nargs := len(resolver.spec.args)
+	if tparams == 0 && nargs == 0 {
+		return named, nil
+	}
+	if tparams == 0 {
+		return nil, fmt.Errorf("%v is not generic but was given %d type arguments", ifaceObj.Name(), nargs)
 	}
-	return ifacePkg, implPkg, nil
+	if nargs == 0 {
+		return nil, fmt.Errorf("generic interface %v requires %d type arguments", ifaceObj.Name(), tparams)
+	}
+	targs, err := resolver.resolveAll()
+	if err != nil {
+		return nil, err
+	}
+	inst, err := types.Instantiate(nil, named, targs, true)
+	if err != nil {
+		return nil, fmt.Errorf("could not instantiate %v: %w", ifaceObj.Name(), err)
+	}
+	return inst.(*types.Named), nil
 }
 
 type mismatchError struct {
@@ -378,20 +699,87 @@ func (me *mismatchError) Error() string {
 	return fmt.Sprintf("mimsatched %q function singatures:\nhave: %s\nwant: %s", me.name, me.have, me.want)
 }
 
+// ConflictError is returned when two of the interfaces implemented
+// onto the same concrete type require a method with the same name
+// but different signatures.
+type ConflictError struct {
+	Method     string    // name of the method
+	Interfaces [2]string // the conflicting interfaces, such as io.Closer
+	Signatures [2]string // the signature each interface requires
+}
+
+func (ce *ConflictError) Error() string {
+	return fmt.Sprintf("conflicting %q method signatures:\n%s: %s\n%s: %s", ce.Method,
+		ce.Interfaces[0], strings.TrimPrefix(ce.Signatures[0], "func"),
+		ce.Interfaces[1], strings.TrimPrefix(ce.Signatures[1], "func"),
+	)
+}
+
+// relativeName returns the name of t along with its type arguments, such as
+// store.Getter[string, models.User], with packages qualified by their name
+// unless they are the concrete type's package.
+func (ct *concreteType) relativeName(t *types.Named) string {
+	return types.TypeString(t, ct.packageQualifier)
+}
+
+// originName is like relativeName but leaves out the
+// package of t itself, such as Getter[string, models.User].
+func (ct *concreteType) originName(t *types.Named) string {
+	name := ct.relativeName(t)
+	if q := ct.packageQualifier(t.Obj().Pkg()); q != "" {
+		name = strings.TrimPrefix(name, q+".")
+	}
+	return name
+}
+
+// packageQualifier is a types.Qualifier that prints packages by their name
+// unless they are the concrete type's package. Unlike qualifier,
+// it never adds imports since it is meant for comments.
+func (ct *concreteType) packageQualifier(pkg *types.Package) string {
+	if pkg == nil || pkg.Path() == ct.pkg.Path() {
+		return ""
+	}
+	return pkg.Name()
+}
+
+// qualifiedName returns the name of t along with its type arguments,
+// qualified by their package names, such as store.Getter[int, models.User].
+func qualifiedName(t *types.Named) string {
+	return types.TypeString(t, (*types.Package).Name)
+}
+
+// TypeSetError is returned when the interface to implement
+// is a constraint with a type set, such as interface{ ~int | ~string }
+// or an interface embedding comparable. Such interfaces can only be used
+// as type parameter constraints, therefore no set of methods can implement them.
+type TypeSetError struct {
+	Interface string // name of the interface
+	TypeSet   string // the underlying constraint interface
+}
+
+func (te *TypeSetError) Error() string {
+	return fmt.Sprintf("%s cannot be implemented: it is a constraint interface (%s) whose type set is not defined by methods alone, and can only be used as a type parameter constraint", te.Interface, te.TypeSet)
+}
+
 // missingInterface represents an interface
 // that has all or some of its methods missing
 // from the destination concrete type
 type missingInterface struct {
+	name    string   // name of the interface with its type arguments, such as Getter[int]
+	chain   []string // interfaces embedding down to this one, relative to the concrete type
 	iface   *types.Interface
 	file    *ast.File
+	pkg     *packages.Package
+	subst   map[*types.TypeParam]types.Type // type arguments of a generic interface
 	missing []*types.Func
+	keys    [][]int // declaration order of each missing method, see declarationKey
 }
 
 // concreteType is the destination type
 // that will implement the interface methods
 type concreteType struct {
 	pkg          *types.Package
-	fset         *token.FileSet
+	pkgs         map[string]*types.Package // all loaded packages by import path
 	file         *ast.File
 	tms, pms     *types.MethodSet
 	addedImports []*AddedImport
@@ -408,11 +796,113 @@ func (ct *concreteType) getMethodSelection(name string) *types.Selection {
 	return ct.pms.Lookup(ct.pkg, name)
 }
 
+// addImport records a new import for the concrete type's file. The file's
+// syntax tree is left untouched since it might be shared across calls.
 func (ct *concreteType) addImport(name, path string) {
-	astutil.AddNamedImport(ct.fset, ct.file, name, path)
 	ct.addedImports = append(ct.addedImports, &AddedImport{name, path})
 }
 
+// lookupImport returns the name under which the concrete type's file imports
+// pkg, taking into account the imports added while generating methods.
+// Imports under one of the taken names are skipped.
+func (ct *concreteType) lookupImport(pkg *types.Package, taken ...string) (string, bool) {
+	for _, imp := range ct.file.Imports {
+		impPath, _ := strconv.Unquote(imp.Path.Value)
+		if impPath != pkg.Path() || isIgnoredImport(imp) {
+			continue
+		}
+		name := pkg.Name()
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		if !slices.Contains(taken, name) {
+			return name, true
+		}
+	}
+	for _, imp := range ct.addedImports {
+		if imp.Path != pkg.Path() {
+			continue
+		}
+		name := pkg.Name()
+		if imp.Name != "" {
+			name = imp.Name
+		}
+		if !slices.Contains(taken, name) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// qualifier is a types.Qualifier that prints packages relative to the
+// concrete type's file, adding an import if the file does not have one yet.
+func (ct *concreteType) qualifier(pkg *types.Package) string {
+	if pkg.Path() == ct.pkg.Path() {
+		return ""
+	}
+	return ct.importName(pkg)
+}
+
+// importName returns the name under which the concrete type's file refers to
+// pkg, importing it under an alias such as errors2 if its name is already taken
+// by another import or by a declaration in the concrete type's package.
+// Names in taken, such as the parameters of the method that refers to pkg,
+// are avoided as well.
+func (ct *concreteType) importName(pkg *types.Package, taken ...string) string {
+	return ct.importAs(pkg, false, taken...)
+}
+
+// importAs is like importName but imports pkg under an explicit
+// alias if explicit is set, even if its name is not taken.
+func (ct *concreteType) importAs(pkg *types.Package, explicit bool, taken ...string) string {
+	if name, ok := ct.lookupImport(pkg, taken...); ok {
+		return name
+	}
+	name, alias := pkg.Name(), ""
+	if explicit {
+		alias = name
+	}
+	for i := 2; ct.isNameTaken(name) || slices.Contains(taken, name); i++ {
+		name = pkg.Name() + strconv.Itoa(i)
+		alias = name
+	}
+	ct.addImport(alias, pkg.Path())
+	return name
+}
+
+// isNameTaken reports whether name is used by the imports of the concrete
+// type's file or a package level declaration of the concrete type.
+func (ct *concreteType) isNameTaken(name string) bool {
+	if ct.pkg.Scope().Lookup(name) != nil {
+		return true
+	}
+	for _, imp := range ct.file.Imports {
+		impPath, _ := strconv.Unquote(imp.Path.Value)
+		if imp.Name != nil && imp.Name.Name == name {
+			return true
+		}
+		if imp.Name == nil && ct.packageName(impPath) == name {
+			return true
+		}
+	}
+	for _, imp := range ct.addedImports {
+		if imp.Name == name || (imp.Name == "" && ct.packageName(imp.Path) == name) {
+			return true
+		}
+	}
+	return false
+}
+
+// packageName returns the name of the package with the given import path,
+// which is guessed from the path if the package is not loaded, such as the
+// imports of an output file that the loaded package does not include.
+func (ct *concreteType) packageName(importPath string) string {
+	if pkg := ct.findPackage(importPath); pkg != nil {
+		return pkg.Name()
+	}
+	return guessPackageName(importPath)
+}
+
 /*
 missingMethods takes a concrete type and returns any missing methods for the given interface as well as
 any missing interface that might have been embedded to its parent. For example:
@@ -434,14 +924,28 @@ returns []*missingInterface{
 	},
 }
 */
-func missingMethods(ct *concreteType, ifaceObj types.Object, ifacePkg *packages.Package, visited map[string]struct{}) ([]*missingInterface, error) {
-	iface, ok := ifaceObj.Type().Underlying().(*types.Interface)
+func missingMethods(ct *concreteType, ifaceType *types.Named, ifacePkg *packages.Package, visited *visitedMethods, chain []string, key []int) ([]*missingInterface, error) {
+	ifaceObj := ifaceType.Obj()
+	chain = append(chain[:len(chain):len(chain)], ct.relativeName(ifaceType))
+	iface, ok := ifaceType.Underlying().(*types.Interface)
 	if !ok {
-		return nil, fmt.Errorf("expected %v to be an interface but got %T", iface, ifaceObj.Type().Underlying())
+		return nil, fmt.Errorf("expected %v to be an interface but got %T", ifaceObj.Name(), ifaceType.Underlying())
 	}
+	if !iface.IsMethodSet() {
+		return nil, &TypeSetError{Interface: ifaceObj.Name(), TypeSet: iface.String()}
+	}
+	_, astFile := getFile(ifacePkg, ifaceObj)
+	if astFile == nil {
+		return nil, fmt.Errorf("could not find ast.File for %v", ifaceObj.Name())
+	}
+	fields := interfaceFields(astFile, ifaceObj)
 	missing := []*missingInterface{}
 	for i := 0; i < iface.NumEmbeddeds(); i++ {
-		eiface := iface.Embedded(i).Obj()
+		embedded, ok := types.Unalias(iface.EmbeddedType(i)).(*types.Named)
+		if !ok {
+			return nil, fmt.Errorf("unsupported embedded type %v in %v", iface.EmbeddedType(i), ifaceObj.Name())
+		}
+		eiface := embedded.Obj()
 		depPkg := ifacePkg
 		if eiface.Pkg().Path() != ifacePkg.Types.Path() {
 			depPkg = ifacePkg.Imports[eiface.Pkg().Path()]
@@ -449,26 +953,38 @@ func missingMethods(ct *concreteType, ifaceObj types.Object, ifacePkg *packages.
 				return nil, fmt.Errorf("missing dependency for %v", eiface.Name())
 			}
 		}
-		em, err := missingMethods(ct, eiface, depPkg, visited)
+		em, err := missingMethods(ct, embedded, depPkg, visited, chain, declarationKey(key, embeddedIndex(fields, i)))
 		if err != nil {
 			return nil, err
 		}
 		missing = append(missing, em...)
 	}
-	_, astFile := getFile(ifacePkg, ifaceObj)
 	mm := &missingInterface{
+		name:  ct.originName(ifaceType),
+		chain: chain,
 		iface: iface,
 		file:  astFile,
-	}
-	if mm.file == nil {
-		return nil, fmt.Errorf("could not find ast.File for %v", ifaceObj.Name())
+		pkg:   ifacePkg,
+		subst: typeParamMap(ifaceType),
 	}
 	for i := 0; i < iface.NumExplicitMethods(); i++ {
 		method := iface.ExplicitMethod(i)
 		if ct.doesNotHaveMethod(method.Name()) {
-			if _, ok := visited[method.Name()]; !ok {
+			v, ok := visited.methods[method.Name()]
+			switch {
+			case !ok:
 				mm.missing = append(mm.missing, method)
-				visited[method.Name()] = struct{}{}
+				mm.keys = append(mm.keys, declarationKey(key, methodIndex(fields, method)))
+				visited.methods[method.Name()] = visitedMethod{iface: qualifiedName(ifaceType), fn: method}
+			case !equalSignatures(v.fn.Type().(*types.Signature), method.Type().(*types.Signature)):
+				return nil, &ConflictError{
+					Method:     method.Name(),
+					Interfaces: [2]string{v.iface, qualifiedName(ifaceType)},
+					Signatures: [2]string{
+						types.TypeString(v.fn.Type(), types.RelativeTo(ct.pkg)),
+						types.TypeString(method.Type(), types.RelativeTo(ct.pkg)),
+					},
+				}
 			}
 		}
 		if sel := ct.getMethodSelection(method.Name()); sel != nil {
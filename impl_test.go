@@ -1,11 +1,20 @@
 package impl
 
 import (
+	"bytes"
+	"errors"
 	"flag"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -16,6 +25,7 @@ var implementTests = []struct {
 	iface       string
 	implPath    string
 	impl        string
+	cfg         *Config
 	goldenFile  string
 }{
 	{
@@ -78,8 +88,399 @@ var implementTests = []struct {
 		impl:       "Underscore",
 		goldenFile: "test_data/underscore/dotter.golden",
 	},
+	{
+		name: "generic interface",
+		description: `
+			If the interface is generic, its type arguments
+			must be substituted in every method signature including
+			the ones of embedded generic interfaces, and any type argument
+			import must be added to the concrete type file.
+		`,
+		ifacePath:  "marwan.io/impl/test_data/store",
+		iface:      "Store[string, *models.Person]",
+		implPath:   "marwan.io/impl/test_data/userdb",
+		impl:       "UserDB",
+		goldenFile: "test_data/userdb/store.golden",
+	},
+	{
+		name: "generic interface with local and full path type arguments",
+		description: `
+			Type arguments can refer to types declared alongside
+			the concrete type or be qualified by their full import path.
+		`,
+		ifacePath:  "marwan.io/impl/test_data/store",
+		iface:      "Store[marwan.io/impl/test_data/models.Beverage, []*User]",
+		implPath:   "marwan.io/impl/test_data/userdb",
+		impl:       "UserDB",
+		goldenFile: "test_data/userdb/store_local.golden",
+	},
+	{
+		name: "generic concrete type",
+		description: `
+			If the concrete type is generic, the receiver must
+			carry its type parameters and the interface can be
+			instantiated with them.
+		`,
+		ifacePath:  "marwan.io/impl/test_data/store",
+		iface:      "Store[K, V]",
+		implPath:   "marwan.io/impl/test_data/cache",
+		impl:       "Cache",
+		goldenFile: "test_data/cache/store.golden",
+	},
+	{
+		name: "grouped type declaration",
+		description: `
+			If the concrete type is declared in a type (...) group,
+			the methods must go after the group's closing parenthesis.
+		`,
+		ifacePath:  "fmt",
+		iface:      "Stringer",
+		implPath:   "marwan.io/impl/test_data/grouped",
+		impl:       "First",
+		goldenFile: "test_data/grouped/stringer.golden",
+	},
+	{
+		name: "auto receiver from existing methods",
+		description: `
+			With AutoReceiver, the generated methods must use
+			the receiver kind of most of the existing methods.
+		`,
+		ifacePath:  "fmt",
+		iface:      "Stringer",
+		implPath:   "marwan.io/impl/test_data/valuer",
+		impl:       "Valuer",
+		cfg:        &Config{Receiver: AutoReceiver},
+		goldenFile: "test_data/valuer/valuer.golden",
+	},
+	{
+		name: "auto receiver for reference types",
+		description: `
+			With AutoReceiver, a map type without methods
+			gets value receivers.
+		`,
+		ifacePath:  "fmt",
+		iface:      "Stringer",
+		implPath:   "marwan.io/impl/test_data/valuer",
+		impl:       "Handlers",
+		cfg:        &Config{Receiver: AutoReceiver},
+		goldenFile: "test_data/valuer/handlers.golden",
+	},
+	{
+		name:       "value receiver",
+		ifacePath:  "io",
+		iface:      "Writer",
+		implPath:   "marwan.io/impl/test_data/goer",
+		impl:       "Goer",
+		cfg:        &Config{Receiver: ValueReceiver},
+		goldenFile: "test_data/goer/value.golden",
+	},
+	{
+		name: "anonymous receiver",
+		description: `
+			An "_" receiver name generates anonymous receivers
+			when there are no existing receiver names to copy.
+		`,
+		ifacePath:  "io",
+		iface:      "Writer",
+		implPath:   "marwan.io/impl/test_data/goer",
+		impl:       "Goer",
+		cfg:        &Config{ReceiverName: "_"},
+		goldenFile: "test_data/goer/anonymous.golden",
+	},
+	{
+		name: "custom template",
+		description: `
+			A custom template has access to the receiver,
+			parameters, results and the declaring interface
+			of each method.
+		`,
+		ifacePath:  "io",
+		iface:      "ReadWriter",
+		implPath:   "marwan.io/impl/test_data/goer",
+		impl:       "Goer",
+		cfg:        &Config{Template: testTemplate},
+		goldenFile: "test_data/goer/template.golden",
+	},
+	{
+		name: "template helpers",
+		description: `
+			Templates can return zero values, name unnamed
+			parameters and refer to types by their import path.
+		`,
+		ifacePath:  "marwan.io/impl/test_data/store",
+		iface:      "Store[string, User]",
+		implPath:   "marwan.io/impl/test_data/userdb",
+		impl:       "UserDB",
+		cfg:        &Config{Template: testHelpersTemplate},
+		goldenFile: "test_data/userdb/helpers.golden",
+	},
+	{
+		name: "template imports",
+		description: `
+			Templates can import packages, which are aliased
+			when a declaration of the package takes their name.
+		`,
+		ifacePath:  "io",
+		iface:      "ReadCloser",
+		implPath:   "marwan.io/impl/test_data/shadow",
+		impl:       "Service",
+		cfg:        &Config{Template: testImportTemplate},
+		goldenFile: "test_data/shadow/imports.golden",
+	},
+	{
+		name:       "zero body",
+		ifacePath:  "io",
+		iface:      "Writer",
+		implPath:   "marwan.io/impl/test_data/goer",
+		impl:       "Goer",
+		cfg:        &Config{Body: ZeroBody},
+		goldenFile: "test_data/goer/zero.golden",
+	},
+	{
+		name: "error body",
+		description: `
+			ErrorBody returns zero values and an error wrapping
+			ErrNotImplemented, which is declared once along with
+			an aliased errors import since the name is taken.
+		`,
+		ifacePath:  "io",
+		iface:      "ReadWriteCloser",
+		implPath:   "marwan.io/impl/test_data/shadow",
+		impl:       "Service",
+		cfg:        &Config{Body: ErrorBody},
+		goldenFile: "test_data/shadow/error.golden",
+	},
+	{
+		name: "error body with shadowing parameter",
+		description: `
+			ErrorBody aliases the fmt import when a
+			parameter of the method is named fmt.
+		`,
+		ifacePath:  "marwan.io/impl/test_data/printer",
+		iface:      "Printer",
+		implPath:   "marwan.io/impl/test_data/printer",
+		impl:       "Console",
+		cfg:        &Config{Body: ErrorBody},
+		goldenFile: "test_data/printer/error.golden",
+	},
+	{
+		name: "error body with anonymous receiver",
+		description: `
+			Without a receiver name, the error message
+			spells out the receiver type instead of using %T.
+		`,
+		ifacePath:  "io",
+		iface:      "ReadWriteCloser",
+		implPath:   "marwan.io/impl/test_data/shadow",
+		impl:       "Service",
+		cfg:        &Config{Body: ErrorBody, ReceiverName: "_"},
+		goldenFile: "test_data/shadow/error_anonymous.golden",
+	},
+	{
+		name: "delegate to a field",
+		description: `
+			Delegating methods forward their calls to a field,
+			naming unnamed parameters and expanding variadic ones.
+		`,
+		ifacePath:  "marwan.io/impl/test_data/traced",
+		iface:      "Store",
+		implPath:   "marwan.io/impl/test_data/traced",
+		impl:       "tracedStore",
+		cfg:        &Config{Delegate: "next"},
+		goldenFile: "test_data/traced/traced.golden",
+	},
+	{
+		name: "only some methods",
+		description: `
+			Methods restricts the generated methods, which
+			can come from embedded interfaces.
+		`,
+		ifacePath:  "io",
+		iface:      "ReadWriteCloser",
+		implPath:   "marwan.io/impl/test_data/shadow",
+		impl:       "Service",
+		cfg:        &Config{Methods: []string{"Read", "Close"}},
+		goldenFile: "test_data/shadow/methods.golden",
+	},
+	{
+		name:       "exclude methods",
+		ifacePath:  "marwan.io/impl/test_data/traced",
+		iface:      "Store",
+		implPath:   "marwan.io/impl/test_data/traced",
+		impl:       "tracedStore",
+		cfg:        &Config{Exclude: []string{"Close", "Keys"}},
+		goldenFile: "test_data/traced/exclude.golden",
+	},
+	{
+		name: "copy doc comments",
+		description: `
+			The doc comments of the interface methods are
+			added beneath the "implements" comment.
+		`,
+		ifacePath:  "marwan.io/impl/test_data/docs",
+		iface:      "Cache",
+		implPath:   "marwan.io/impl/test_data/docs",
+		impl:       "LRU",
+		cfg:        &Config{Doc: CopyDoc},
+		goldenFile: "test_data/docs/copy.golden",
+	},
+	{
+		name: "replace doc comments",
+		description: `
+			The doc comments of the interface methods replace the
+			"implements" comment of the methods that have one.
+		`,
+		ifacePath:  "marwan.io/impl/test_data/docs",
+		iface:      "Cache",
+		implPath:   "marwan.io/impl/test_data/docs",
+		impl:       "LRU",
+		cfg:        &Config{Doc: ReplaceDoc},
+		goldenFile: "test_data/docs/replace.golden",
+	},
+	{
+		name: "embedding chain",
+		description: `
+			Methods of embedded interfaces are attributed to the
+			chain of interfaces that embeds them.
+		`,
+		ifacePath:  "marwan.io/impl/test_data/partier",
+		iface:      "Partier",
+		implPath:   "marwan.io/impl/test_data/goer",
+		impl:       "Goer",
+		cfg:        &Config{Chain: true},
+		goldenFile: "test_data/goer/chain.golden",
+	},
+	{
+		name: "declaration order",
+		description: `
+			Methods are generated in the order they are declared,
+			including the ones of embedded interfaces.
+		`,
+		ifacePath:  "marwan.io/impl/test_data/partier",
+		iface:      "Partier",
+		implPath:   "marwan.io/impl/test_data/goer",
+		impl:       "Goer",
+		cfg:        &Config{Order: DeclarationOrder},
+		goldenFile: "test_data/goer/declaration.golden",
+	},
+	{
+		name:       "alphabetical order",
+		ifacePath:  "marwan.io/impl/test_data/partier",
+		iface:      "Partier",
+		implPath:   "marwan.io/impl/test_data/goer",
+		impl:       "Goer",
+		cfg:        &Config{Order: AlphabeticalOrder},
+		goldenFile: "test_data/goer/alphabetical.golden",
+	},
+	{
+		name: "grouped order",
+		description: `
+			Methods are grouped by the interface that declares
+			them, with a comment before each group.
+		`,
+		ifacePath:  "marwan.io/impl/test_data/ordered",
+		iface:      "Conn",
+		implPath:   "marwan.io/impl/test_data/ordered",
+		impl:       "Client",
+		cfg:        &Config{Order: GroupedOrder},
+		goldenFile: "test_data/ordered/grouped.golden",
+	},
+	{
+		name: "declaration order with embedded interfaces in between",
+		description: `
+			Methods of embedded interfaces are generated
+			where the interface embeds them.
+		`,
+		ifacePath:  "marwan.io/impl/test_data/ordered",
+		iface:      "Conn",
+		implPath:   "marwan.io/impl/test_data/ordered",
+		impl:       "Client",
+		cfg:        &Config{Order: DeclarationOrder},
+		goldenFile: "test_data/ordered/declaration.golden",
+	},
+	{
+		name: "after the last method",
+		description: `
+			Methods are inserted after the last method
+			of the type declared in the same file.
+		`,
+		ifacePath:  "io",
+		iface:      "Closer",
+		implPath:   "marwan.io/impl/test_data/placed",
+		impl:       "Server",
+		cfg:        &Config{Placement: AfterMethodsPlacement},
+		goldenFile: "test_data/placed/methods.golden",
+	},
+	{
+		name: "after the type without methods",
+		description: `
+			Methods are inserted after the type
+			if it has no methods in the same file.
+		`,
+		ifacePath:  "io",
+		iface:      "Closer",
+		implPath:   "marwan.io/impl/test_data/placed",
+		impl:       "Client",
+		cfg:        &Config{Placement: AfterMethodsPlacement},
+		goldenFile: "test_data/placed/no_methods.golden",
+	},
+	{
+		name:       "end of file",
+		ifacePath:  "io",
+		iface:      "Closer",
+		implPath:   "marwan.io/impl/test_data/placed",
+		impl:       "Client",
+		cfg:        &Config{Placement: EndOfFilePlacement},
+		goldenFile: "test_data/placed/end.golden",
+	},
+	{
+		name:       "marker",
+		ifacePath:  "io",
+		iface:      "Closer",
+		implPath:   "marwan.io/impl/test_data/placed",
+		impl:       "Client",
+		cfg:        &Config{Placement: MarkerPlacement},
+		goldenFile: "test_data/placed/marker.golden",
+	},
+	{
+		name: "existing output file",
+		description: `
+			Methods go to the end of an existing output file, which
+			is the file whose imports the signatures must use.
+		`,
+		ifacePath:  "io",
+		iface:      "WriterTo",
+		implPath:   "marwan.io/impl/test_data/split",
+		impl:       "Store",
+		cfg:        &Config{Output: "existing.go"},
+		goldenFile: "test_data/split/existing.golden",
+	},
 }
 
+const testTemplate = `// {{ .Name }} implements {{ .OriginPath }}.{{ .Origin }} for {{ .InterfacePath }}.{{ .Interface }}.
+func ({{ .ReceiverName }} {{ .ReceiverType }}) {{ .Name }}(
+{{- range $i, $p := .Params }}{{ if $i }}, {{ end }}{{ $p.Name }} {{ $p.Type }}{{ end -}}
+) ({{ range $i, $r := .Results }}{{ if $i }}, {{ end }}{{ $r.Type }}{{ end }}) {
+	{{ if .ReturnsError }}return 0, nil{{ else }}panic("unimplemented"){{ end }}
+}
+`
+
+const testHelpersTemplate = `// {{ .Name }} implements {{ .Interface }}
+func ({{ .Receiver }}) {{ .Name }}(
+{{- range $i, $p := paramNames .Params }}{{ if $i }}, {{ end }}{{ $p.Name }} {{ $p.Type }}{{ end -}}
+) ({{ range $i, $r := .Results }}{{ if $i }}, {{ end }}{{ $r.Type }}{{ end }}) {
+	var _ {{ qualify "*marwan.io/impl/test_data/userdb.User" }}
+	var _ {{ qualify "map[string]marwan.io/impl/test_data/models.Person" }}
+	return {{ range $i, $r := .Results }}{{ if $i }}, {{ end }}{{ zero $r }}{{ end }}
+}
+`
+
+const testImportTemplate = `// {{ .Name }} implements {{ .Interface }}
+func ({{ .Receiver }}) {{ .Name }}{{ .Signature }} {
+	return {{ range $i, $r := .Results }}{{ if $i }}, {{ end }}{{ if eq $r.Type "error" }}{{ import "errors" }}.New("unimplemented"){{ else }}{{ zero $r }}{{ end }}{{ end }}
+}
+`
+
 var u = flag.Bool("u", false, "override and update golden files")
 
 func TestMain(m *testing.M) {
@@ -87,10 +488,26 @@ func TestMain(m *testing.M) {
 	os.Exit(m.Run())
 }
 
+// assertGolden compares got to the content of the given golden file,
+// which is overwritten with got first when the -u flag is set.
+func assertGolden(t *testing.T, goldenFile string, got []byte) {
+	t.Helper()
+	if *u {
+		if err := ioutil.WriteFile(goldenFile, got, 0660); err != nil {
+			t.Fatalf("could not write %q golden file: %v", goldenFile, err)
+		}
+	}
+	want, err := ioutil.ReadFile(goldenFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	require.Equal(t, string(want), string(got), "expected to match golden file %v", goldenFile)
+}
+
 func TestImplement(t *testing.T) {
 	for _, tc := range implementTests {
 		t.Run(tc.name, func(t *testing.T) {
-			imp, err := Implement(tc.ifacePath, tc.iface, tc.implPath, tc.impl)
+			imp, err := ImplementWithConfig(tc.cfg, tc.ifacePath, tc.iface, tc.implPath, tc.impl)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -110,6 +527,499 @@ func TestImplement(t *testing.T) {
 	}
 }
 
+func TestImplementEdits(t *testing.T) {
+	for _, tc := range implementTests {
+		t.Run(tc.name, func(t *testing.T) {
+			imp, err := ImplementWithConfig(tc.cfg, tc.ifacePath, tc.iface, tc.implPath, tc.impl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			src, err := ioutil.ReadFile(imp.File)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// applying the edits from last to first keeps
+			// the offsets of the remaining edits valid.
+			for i := len(imp.Edits) - 1; i >= 0; i-- {
+				e := imp.Edits[i]
+				src = append(src[:e.Range.Start.Offset], append([]byte(e.NewText), src[e.Range.End.Offset:]...)...)
+			}
+			got, err := format.Source(src)
+			if err != nil {
+				t.Fatalf("edits produced invalid Go: %v\n%s", err, src)
+			}
+			require.Equal(t, string(imp.FileContent), string(got), "expected edits to match the file content")
+		})
+	}
+}
+
+func TestOffsetPosition(t *testing.T) {
+	src := []byte("package a\n// héllo 🎉 world\n")
+	offset := bytes.Index(src, []byte("world"))
+	require.Equal(t, Position{Line: 1, Character: 12, Offset: offset}, offsetPosition(src, offset))
+}
+
+func TestSession(t *testing.T) {
+	s, err := NewSession(nil, "marwan.io/impl/test_data/...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// run every test case twice and concurrently to make sure
+	// the shared packages are never modified by a single call.
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		for _, tc := range implementTests {
+			tc := tc
+			if tc.cfg != nil {
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				imp, err := s.Implement(tc.ifacePath, tc.iface, tc.implPath, tc.impl)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				want, err := ioutil.ReadFile(tc.goldenFile)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				assert.Equal(t, string(want), string(imp.FileContent), "%s: expected to match golden file", tc.name)
+			}()
+		}
+	}
+	wg.Wait()
+	ok, err := s.Implements("io", "Closer", "marwan.io/impl/test_data/goer", "Goer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	require.True(t, ok, "expected Goer to implement io.Closer")
+	ok, err = s.Implements("io", "Writer", "marwan.io/impl/test_data/goer", "Goer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	require.False(t, ok, "expected Goer not to implement io.Writer")
+}
+
+func TestSessionInvalidate(t *testing.T) {
+	// relative file names are resolved against Config.Dir
+	cfg := &Config{Dir: "test_data", Overlay: map[string][]byte{
+		"goer/goer.go": []byte("package goer\n\ntype Dancer struct{}\n"),
+	}}
+	s, err := NewSession(cfg, "marwan.io/impl/test_data/goer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	imp, err := s.Implement("io", "Writer", "marwan.io/impl/test_data/goer", "Dancer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	require.NotNil(t, imp, "expected Dancer to miss io.Writer")
+	cfg.Overlay["goer/goer.go"] = []byte("package goer\n\ntype Dancer struct{}\n\nfunc (d *Dancer) Write(p []byte) (int, error) { return len(p), nil }\n")
+	s.Invalidate("goer/goer.go")
+	imp, err = s.Implement("io", "Writer", "marwan.io/impl/test_data/goer", "Dancer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	require.Nil(t, imp, "expected the session to reload Dancer with its Write method")
+	for i := 0; i < 3; i++ {
+		s.Invalidate()
+		if _, err := s.Implement("io", "Closer", "marwan.io/impl/test_data/goer", "Dancer"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	require.Len(t, s.patterns, 2, "expected every pattern to be loaded once")
+}
+
+func TestImplementBatch(t *testing.T) {
+	pairs := []Pair{
+		{IfacePath: "io", Iface: "Writer", ImplPath: "marwan.io/impl/test_data/goer", Impl: "Goer"},
+		{IfacePath: "marwan.io/impl/test_data/rioter", Iface: "Rioter", ImplPath: "marwan.io/impl/test_data/userdb", Impl: "UserDB"},
+		{IfacePath: "marwan.io/impl/test_data/partier", Iface: "Partier", ImplPath: "marwan.io/impl/test_data/goer", Impl: "Goer"},
+	}
+	impls, err := ImplementBatch(nil, pairs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	require.Len(t, impls, 2, "expected one implementation per file")
+	assertGolden(t, "test_data/goer/batch.golden", impls[0].FileContent)
+	require.Contains(t, string(impls[1].Methods), "func (u *UserDB) Riot(c *crowd.Crowd)")
+}
+
+func TestImplementWithConfig(t *testing.T) {
+	_, err := Implement("marwan.io/impl/test_data/rioter", "Rioter", "marwan.io/impl/test_data/tagged", "Tagged")
+	require.Error(t, err, "expected type to be hidden without build tags")
+	cfg := &Config{Tags: []string{"impltag"}}
+	imp, err := ImplementWithConfig(cfg, "marwan.io/impl/test_data/rioter", "Rioter", "marwan.io/impl/test_data/tagged", "Tagged")
+	if err != nil {
+		t.Fatal(err)
+	}
+	require.Contains(t, string(imp.Methods), "func (t *Tagged) Riot(c *crowd.Crowd)")
+}
+
+func TestImplementOverlay(t *testing.T) {
+	cfg := &Config{Overlay: map[string][]byte{
+		"test_data/goer/goer.go": []byte("package goer\n\n// Dancer only exists in the editor\ntype Dancer struct{}\n"),
+	}}
+	imp, err := ImplementWithConfig(cfg, "io", "Writer", "marwan.io/impl/test_data/goer", "Dancer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "package goer\n\n// Dancer only exists in the editor\ntype Dancer struct{}\n\n" +
+		"// Write implements io.Writer\nfunc (d *Dancer) Write(p []byte) (n int, err error) {\n\tpanic(\"unimplemented\")\n}\n"
+	require.Equal(t, want, string(imp.FileContent))
+	require.Equal(t, string(cfg.Overlay["test_data/goer/goer.go"]), string(imp.Original))
+}
+
+func TestImplementOverlayDelete(t *testing.T) {
+	// Store reads in existing.go, which the editor deleted
+	cfg := &Config{Dir: "test_data", Overlay: map[string][]byte{"split/existing.go": nil}}
+	imp, err := ImplementWithConfig(cfg, "io", "Reader", "marwan.io/impl/test_data/split", "Store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	require.Contains(t, string(imp.Methods), "func (s *Store) Read(p []byte)")
+	cfg.Output = "existing.go"
+	imp, err = ImplementWithConfig(cfg, "io", "Reader", "marwan.io/impl/test_data/split", "Store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	require.True(t, imp.NewFile, "expected the deleted file to be created again")
+	require.Nil(t, imp.Original)
+}
+
+func TestTypeAt(t *testing.T) {
+	tt := []struct {
+		line, col int
+		want      string
+	}{
+		{line: 5, col: 3, want: "First"},
+		{line: 9, col: 4, want: "Second"},
+		{line: 14, col: 1, want: "Third"},
+		{line: 18, col: 5, want: "Second"},
+	}
+	for _, tc := range tt {
+		path, name, err := TypeAt(nil, "test_data/grouped/grouped.go", tc.line, tc.col)
+		if err != nil {
+			t.Fatal(err)
+		}
+		require.Equal(t, "marwan.io/impl/test_data/grouped", path)
+		require.Equal(t, tc.want, name, "line %d col %d", tc.line, tc.col)
+	}
+	_, _, err := TypeAt(nil, "test_data/grouped/grouped.go", 3, 1)
+	require.Error(t, err, "expected an error for a position between grouped types")
+	for _, col := range []int{0, 40} {
+		_, _, err = TypeAt(nil, "test_data/grouped/grouped.go", 13, col)
+		require.Error(t, err, "expected an error for column %d", col)
+		require.Contains(t, err.Error(), "column out of range")
+	}
+}
+
+func TestImplementTypeSet(t *testing.T) {
+	for _, iface := range []string{"Stringish", "Comparer"} {
+		t.Run(iface, func(t *testing.T) {
+			_, err := Implement("marwan.io/impl/test_data/constraint", iface, "marwan.io/impl/test_data/constraint", "Value")
+			var tse *TypeSetError
+			require.True(t, errors.As(err, &tse), "expected a TypeSetError but got %v", err)
+			require.Equal(t, iface, tse.Interface)
+		})
+	}
+}
+
+func TestImplementBadTemplate(t *testing.T) {
+	cfg := &Config{Template: "{{ .Name "}
+	_, err := ImplementWithConfig(cfg, "io", "Writer", "marwan.io/impl/test_data/goer", "Goer")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "could not parse method template")
+}
+
+func TestImplementOrigin(t *testing.T) {
+	cfg := &Config{Template: "// {{ .Name }}: {{ .Origin }} in {{ .OriginPath }}\n"}
+	imp, err := ImplementWithConfig(cfg, "marwan.io/impl/test_data/store", "Store[string, *models.Person]", "marwan.io/impl/test_data/userdb", "UserDB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	require.Contains(t, string(imp.Methods), "// Get: Getter[string, *models.Person] in marwan.io/impl/test_data/store\n")
+	require.Contains(t, string(imp.Methods), "// Put: Store[string, *models.Person] in marwan.io/impl/test_data/store\n")
+}
+
+func TestImplementBatchErrorBody(t *testing.T) {
+	pairs := []Pair{
+		{IfacePath: "io", Iface: "Closer", ImplPath: "marwan.io/impl/test_data/userdb", Impl: "UserDB"},
+		{IfacePath: "io", Iface: "Closer", ImplPath: "marwan.io/impl/test_data/userdb", Impl: "User"},
+	}
+	impls, err := ImplementBatch(&Config{Body: ErrorBody}, pairs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	require.Len(t, impls, 1)
+	require.Equal(t, 1, bytes.Count(impls[0].FileContent, []byte("var ErrNotImplemented =")))
+}
+
+func TestImplementDelegateErrors(t *testing.T) {
+	for _, tc := range []struct {
+		impl, delegate, err string
+	}{
+		{"tracedStore", "prev", `tracedStore has no field "prev" to delegate to`},
+		{"readerStore", "next", "field next of type io.Reader has no method Close"},
+		{"mapStore", "next", "cannot delegate Get to field next"},
+	} {
+		t.Run(tc.impl, func(t *testing.T) {
+			cfg := &Config{Delegate: tc.delegate}
+			_, err := ImplementWithConfig(cfg, "marwan.io/impl/test_data/traced", "Store", "marwan.io/impl/test_data/traced", tc.impl)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
+
+func TestImplementUnknownMethods(t *testing.T) {
+	for _, cfg := range []*Config{
+		{Methods: []string{"Read", "Seek"}},
+		{Exclude: []string{"Seek"}},
+	} {
+		_, err := ImplementWithConfig(cfg, "io", "ReadWriteCloser", "marwan.io/impl/test_data/shadow", "Service")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), `ReadWriteCloser has no method "Seek"`)
+	}
+}
+
+func TestImplementInterfaces(t *testing.T) {
+	ifaces := []Interface{
+		{Path: "net/http", Name: "Handler"},
+		{Path: "io", Name: "Closer"},
+		{Path: "marwan.io/impl/test_data/health", Name: "Healthchecker"},
+	}
+	imp, err := ImplementInterfaces(nil, ifaces, "marwan.io/impl/test_data/health", "Handler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "test_data/health/handler.golden", imp.FileContent)
+}
+
+func TestImplementInterfacesSamePackageName(t *testing.T) {
+	ifaces := []Interface{
+		{Path: "marwan.io/impl/test_data/stock", Name: "Host"},
+		{Path: "marwan.io/impl/test_data/stock/models", Name: "Stocker"},
+	}
+	imp, err := ImplementInterfaces(nil, ifaces, "marwan.io/impl/test_data/stock", "Shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "test_data/stock/shop.golden", imp.FileContent)
+}
+
+func TestImplementInterfacesConflict(t *testing.T) {
+	ifaces := []Interface{
+		{Path: "io", Name: "Closer"},
+		{Path: "marwan.io/impl/test_data/health", Name: "Stopper"},
+	}
+	_, err := ImplementInterfaces(nil, ifaces, "marwan.io/impl/test_data/health", "Handler")
+	var ce *ConflictError
+	require.True(t, errors.As(err, &ce), "expected a ConflictError but got %v", err)
+	require.Equal(t, "Close", ce.Method)
+	require.Equal(t, [2]string{"io.Closer", "health.Stopper"}, ce.Interfaces)
+	require.Equal(t, [2]string{"func() error", "func()"}, ce.Signatures)
+}
+
+func TestImplementInterfacesFilteredConflict(t *testing.T) {
+	ifaces := []Interface{
+		{Path: "io", Name: "Closer"},
+		{Path: "marwan.io/impl/test_data/health", Name: "Stopper"},
+	}
+	cfg := &Config{Exclude: []string{"Close"}}
+	_, err := ImplementInterfaces(cfg, ifaces, "marwan.io/impl/test_data/health", "Handler")
+	var ce *ConflictError
+	require.True(t, errors.As(err, &ce), "expected a ConflictError but got %v", err)
+	require.Equal(t, [2]string{"io.Closer", "health.Stopper"}, ce.Interfaces)
+}
+
+func TestImplementInterfacesGenericConflict(t *testing.T) {
+	ifaces := []Interface{
+		{Path: "marwan.io/impl/test_data/store", Name: "Getter[string, int]"},
+		{Path: "marwan.io/impl/test_data/store", Name: "Getter[string, *models.Person]"},
+	}
+	_, err := ImplementInterfaces(nil, ifaces, "marwan.io/impl/test_data/health", "Handler")
+	var ce *ConflictError
+	require.True(t, errors.As(err, &ce), "expected a ConflictError but got %v", err)
+	require.Equal(t, [2]string{"store.Getter[string, int]", "store.Getter[string, *models.Person]"}, ce.Interfaces)
+}
+
+func TestImplementMissingMarker(t *testing.T) {
+	cfg := &Config{Placement: MarkerPlacement}
+	_, err := ImplementWithConfig(cfg, "io", "Closer", "marwan.io/impl/test_data/placed", "Proxy")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "could not find the //impl:insert-here marker")
+}
+
+func TestImplementMisplacedMarker(t *testing.T) {
+	cfg := &Config{Placement: MarkerPlacement}
+	_, err := ImplementWithConfig(cfg, "io", "Closer", "marwan.io/impl/test_data/placed", "Gateway")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "misplaced.go:7:2 must be at the top level of the file")
+}
+
+func TestImplementOutput(t *testing.T) {
+	cfg := &Config{Output: "store_writer.go"}
+	imp, err := ImplementWithConfig(cfg, "io", "WriterTo", "marwan.io/impl/test_data/split", "Store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	require.Equal(t, "store_writer.go", filepath.Base(imp.File))
+	require.True(t, imp.NewFile, "expected store_writer.go to be a new file")
+	assertGolden(t, "test_data/split/new.golden", imp.FileContent)
+	require.Equal(t, []TextEdit{{NewText: string(imp.FileContent)}}, imp.Edits)
+}
+
+func TestImplementOutputOtherDir(t *testing.T) {
+	cfg := &Config{Output: "test_data/goer/store_writer.go"}
+	_, err := ImplementWithConfig(cfg, "io", "WriterTo", "marwan.io/impl/test_data/split", "Store")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "must be in the directory of marwan.io/impl/test_data/split")
+}
+
+func TestImplementNew(t *testing.T) {
+	for _, tc := range []struct {
+		name, impl, output, file, goldenFile string
+		ifaces                               []Interface
+		newFile                              bool
+	}{
+		{"new file", "Archive", "", "archive.go", "test_data/split/archive.golden", []Interface{{"io", "ReadCloser"}}, true},
+		{"existing file", "nopArchive", "existing.go", "existing.go", "test_data/split/existing_new.golden", []Interface{{"io", "Closer"}}, false},
+		{"several interfaces", "Archive", "", "archive.go", "test_data/split/archive_ifaces.golden", []Interface{{"io", "ReadCloser"}, {"io", "WriteCloser"}}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &Config{New: true, Constructor: true, Output: tc.output}
+			imp, err := ImplementInterfaces(cfg, tc.ifaces, "marwan.io/impl/test_data/split", tc.impl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			require.Equal(t, tc.file, filepath.Base(imp.File))
+			require.Equal(t, tc.newFile, imp.NewFile)
+			assertGolden(t, tc.goldenFile, imp.FileContent)
+		})
+	}
+}
+
+func TestImplementNewExistingType(t *testing.T) {
+	cfg := &Config{New: true, Output: "store_writer.go"}
+	imp, err := ImplementWithConfig(cfg, "io", "WriterTo", "marwan.io/impl/test_data/split", "Store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "test_data/split/new.golden", imp.FileContent)
+}
+
+func TestImplementMissingType(t *testing.T) {
+	_, err := Implement("io", "Closer", "marwan.io/impl/test_data/split", "Archive")
+	require.EqualError(t, err, "could not find type declaration (Archive) in marwan.io/impl/test_data/split")
+}
+
+func TestZeroValue(t *testing.T) {
+	pkg := types.NewPackage("example.com/models", "models")
+	named := func(name string, underlying types.Type) types.Type {
+		return types.NewNamed(types.NewTypeName(token.NoPos, pkg, name, nil), underlying, nil)
+	}
+	ct := &concreteType{pkg: types.NewPackage("example.com/pkg", "pkg"), file: &ast.File{}}
+	for _, tc := range []struct {
+		typ  types.Type
+		want string
+	}{
+		{types.Typ[types.Int], "0"},
+		{types.Typ[types.Float64], "0"},
+		{types.Typ[types.String], `""`},
+		{types.Typ[types.Bool], "false"},
+		{named("Duration", types.Typ[types.Int64]), "0"},
+		{types.NewPointer(types.Typ[types.Int]), "nil"},
+		{types.NewSlice(types.Typ[types.Byte]), "nil"},
+		{types.Universe.Lookup("error").Type(), "nil"},
+		{types.NewArray(types.Typ[types.Int], 3), "[3]int{}"},
+		{named("User", types.NewStruct(nil, nil)), "models.User{}"},
+	} {
+		require.Equal(t, tc.want, ct.zeroValue(tc.typ), "zero value of %v", tc.typ)
+	}
+}
+
+func TestQualify(t *testing.T) {
+	models := types.NewPackage("example.com/models", "models")
+	yaml := types.NewPackage("gopkg.in/yaml.v2", "yaml")
+	ct := &concreteType{
+		pkg:  types.NewPackage("example.com/pkg", "pkg"),
+		pkgs: map[string]*types.Package{models.Path(): models, yaml.Path(): yaml, "context": types.NewPackage("context", "context")},
+		file: &ast.File{},
+	}
+	for _, tc := range []struct{ typ, want string }{
+		{"*example.com/models.User", "*models.User"},
+		{"map[string]context.Context", "map[string]context.Context"},
+		{"...gopkg.in/yaml.v2.Node", "...yaml.Node"},
+		{"[]example.com/pkg.Item", "[]Item"},
+		{"func(example.com/models.User) error", "func(models.User) error"},
+	} {
+		got, err := ct.qualify(tc.typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		require.Equal(t, tc.want, got, "qualified %v", tc.typ)
+	}
+	require.Equal(t, []*AddedImport{{"", "example.com/models"}, {"", "context"}, {"", "gopkg.in/yaml.v2"}}, ct.addedImports)
+	// the types of MethodData are already relative to the concrete type's file
+	_, err := ct.qualify("*models.User")
+	require.EqualError(t, err, `could not qualify "*models.User": package "models" is not loaded`)
+}
+
+func TestImportPath(t *testing.T) {
+	models := types.NewPackage("example.com/models", "models")
+	ct := &concreteType{
+		pkg:  types.NewPackage("example.com/pkg", "pkg"),
+		pkgs: map[string]*types.Package{models.Path(): models},
+		file: &ast.File{},
+	}
+	for _, tc := range []struct{ path, want string }{
+		{"example.com/models", "models"},
+		{"gopkg.in/yaml.v2", "yaml"},
+		{"example.com/foo-bar/v2", "foobar"},
+		{"gopkg.in/yaml.v2", "yaml"},
+		{"example.com/other/yaml", "yaml2"},
+	} {
+		got, err := ct.importPath(tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		require.Equal(t, tc.want, got, "imported %v", tc.path)
+	}
+	require.Equal(t, []*AddedImport{
+		{"", "example.com/models"},
+		{"yaml", "gopkg.in/yaml.v2"},
+		{"foobar", "example.com/foo-bar/v2"},
+		{"yaml2", "example.com/other/yaml"},
+	}, ct.addedImports)
+	_, err := ct.importPath("example.com/3d")
+	require.Error(t, err, "expected an error for a path that is not a valid package name")
+}
+
+func TestListInterfaces(t *testing.T) {
+	ifaces, err := ListInterfaces("marwan.io/impl/test_data/constraint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	require.Equal(t, []string{"marwan.io/impl/test_data/constraint.Stringer"}, ifaces)
+}
+
+func TestListInterfacesWithConfig(t *testing.T) {
+	ifaces, err := ListInterfaces("marwan.io/impl/test_data/tagged")
+	if err != nil {
+		t.Fatal(err)
+	}
+	require.Empty(t, ifaces, "expected Tagger to be hidden without build tags")
+	ifaces, err = ListInterfacesWithConfig(&Config{Tags: []string{"impltag"}}, "marwan.io/impl/test_data/tagged")
+	if err != nil {
+		t.Fatal(err)
+	}
+	require.Equal(t, []string{"marwan.io/impl/test_data/tagged.Tagger"}, ifaces)
+}
+
 func BenchmarkImplementation(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		_, err := Implement("marwan.io/impl/test_data/partier", "Partier", "marwan.io/impl/test_data/goer", "Goer")
@@ -118,3 +1028,17 @@ func BenchmarkImplementation(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkSession(b *testing.B) {
+	s, err := NewSession(nil, "marwan.io/impl/test_data/...")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := s.Implement("marwan.io/impl/test_data/partier", "Partier", "marwan.io/impl/test_data/goer", "Goer")
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package impl
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// newConcreteType returns a concrete type that does not exist in pkg yet,
+// which is an empty struct that has no methods. It is not added to the
+// scope of pkg since the loaded packages may be shared by several calls.
+func newConcreteType(pkg *types.Package, name string) (types.Object, error) {
+	if !token.IsIdentifier(name) || name == "_" {
+		return nil, fmt.Errorf("cannot declare a type named %q", name)
+	}
+	obj := types.NewTypeName(token.NoPos, pkg, name, nil)
+	types.NewNamed(obj, types.NewStruct(nil, nil), nil)
+	return obj, nil
+}
+
+// typeDecl returns the declaration of a concrete type that does not exist,
+// along with its constructor if Config.Constructor is set.
+func (tt *target) typeDecl(cfg *Config, receiver string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "// %s implements %s\ntype %s struct{}\n\n", tt.impl, joinNames(tt.ifaceNames), tt.impl)
+	if !cfg.Constructor {
+		return b.String()
+	}
+	name := constructorName(tt.impl)
+	fmt.Fprintf(&b, "// %s returns a new %s\nfunc %s() %s {\n", name, tt.impl, name, receiver)
+	if strings.HasPrefix(receiver, "*") {
+		fmt.Fprintf(&b, "\treturn &%s{}\n}\n\n", tt.impl)
+	} else {
+		fmt.Fprintf(&b, "\treturn %s{}\n}\n\n", tt.impl)
+	}
+	return b.String()
+}
+
+// joinNames lists names in prose, such as "io.Reader, io.Writer and io.Closer".
+func joinNames(names []string) string {
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
+
+// constructorName returns the name of the function that creates a new
+// concrete type, which is unexported if the concrete type is.
+func constructorName(impl string) string {
+	if token.IsExported(impl) {
+		return "New" + impl
+	}
+	r, size := utf8.DecodeRuneInString(impl)
+	return "new" + string(unicode.ToUpper(r)) + impl[size:]
+}
@@ -0,0 +1,172 @@
+package impl
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// OrderKind defines the order of the generated methods
+type OrderKind int
+
+// Order kinds
+const (
+	// EmbeddedFirstOrder generates the methods of embedded interfaces first,
+	// followed by the interface's own methods sorted by name.
+	EmbeddedFirstOrder OrderKind = iota
+	// DeclarationOrder generates the methods in the order they are declared
+	// in the interface, including the ones of embedded interfaces.
+	DeclarationOrder
+	// AlphabeticalOrder generates the methods sorted by name.
+	AlphabeticalOrder
+	// GroupedOrder generates the methods in declaration order, grouped by
+	// the interface that declares them with a comment before each group.
+	GroupedOrder
+)
+
+func (ok OrderKind) String() string {
+	switch ok {
+	case EmbeddedFirstOrder:
+		return "embedded"
+	case DeclarationOrder:
+		return "declaration"
+	case AlphabeticalOrder:
+		return "alphabetical"
+	case GroupedOrder:
+		return "grouped"
+	}
+	return fmt.Sprintf("OrderKind(%d)", int(ok))
+}
+
+// ParseOrderKind parses "embedded", "declaration", "alphabetical" or "grouped" into an OrderKind
+func ParseOrderKind(s string) (OrderKind, error) {
+	for _, ok := range []OrderKind{EmbeddedFirstOrder, DeclarationOrder, AlphabeticalOrder, GroupedOrder} {
+		if strings.EqualFold(s, ok.String()) {
+			return ok, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown order %q, expected embedded, declaration, alphabetical or grouped", s)
+}
+
+// stub is a missing method along with the interface that declares it
+type stub struct {
+	mm  *missingInterface
+	fn  *types.Func
+	key []int
+}
+
+// stubs returns the missing methods of the target in the configured order
+func (tt *target) stubs(cfg *Config) []stub {
+	stubs := []stub{}
+	for _, mm := range tt.missing {
+		for i, m := range mm.missing {
+			stubs = append(stubs, stub{mm: mm, fn: m, key: mm.keys[i]})
+		}
+	}
+	order := EmbeddedFirstOrder
+	if cfg != nil {
+		order = cfg.Order
+	}
+	switch order {
+	case DeclarationOrder:
+		sort.SliceStable(stubs, func(i, j int) bool {
+			return lessKey(stubs[i].key, stubs[j].key)
+		})
+	case AlphabeticalOrder:
+		sort.SliceStable(stubs, func(i, j int) bool {
+			return stubs[i].fn.Name() < stubs[j].fn.Name()
+		})
+	case GroupedOrder:
+		sort.SliceStable(stubs, func(i, j int) bool {
+			return lessKey(stubs[i].key, stubs[j].key)
+		})
+		// keep each group where its first method is declared
+		first := map[*missingInterface]int{}
+		for i, s := range stubs {
+			if _, ok := first[s.mm]; !ok {
+				first[s.mm] = i
+			}
+		}
+		sort.SliceStable(stubs, func(i, j int) bool {
+			return first[stubs[i].mm] < first[stubs[j].mm]
+		})
+	}
+	return stubs
+}
+
+// groupComment returns the comment that separates the methods of
+// each interface with GroupedOrder, or an empty string if s does
+// not start a new group.
+func groupComment(cfg *Config, stubs []stub, i int) string {
+	if cfg == nil || cfg.Order != GroupedOrder || (i > 0 && stubs[i-1].mm == stubs[i].mm) {
+		return ""
+	}
+	name := stubs[i].mm.chain[len(stubs[i].mm.chain)-1]
+	if cfg.Chain {
+		name = strings.Join(stubs[i].mm.chain, " > ")
+	}
+	return "// " + name + " methods\n\n"
+}
+
+// interfaceFields returns the methods and embedded
+// interfaces of the interface declared by obj in file.
+func interfaceFields(file *ast.File, obj types.Object) []*ast.Field {
+	path, _ := astutil.PathEnclosingInterval(file, obj.Pos(), obj.Pos())
+	for _, n := range path {
+		ts, ok := n.(*ast.TypeSpec)
+		if !ok {
+			continue
+		}
+		it, ok := ts.Type.(*ast.InterfaceType)
+		if !ok || it.Methods == nil {
+			return nil
+		}
+		return it.Methods.List
+	}
+	return nil
+}
+
+// embeddedIndex returns the index of the field that embeds the
+// nth embedded interface, which go/types keeps in source order.
+func embeddedIndex(fields []*ast.Field, n int) int {
+	for i, f := range fields {
+		if len(f.Names) > 0 {
+			continue
+		}
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	return len(fields) + n
+}
+
+// methodIndex returns the index of the field that declares fn
+func methodIndex(fields []*ast.Field, fn *types.Func) int {
+	for i, f := range fields {
+		if f.Pos() <= fn.Pos() && fn.Pos() < f.End() {
+			return i
+		}
+	}
+	return len(fields)
+}
+
+// declarationKey returns the position of a method or embedded interface
+// declared at index i of an interface itself declared at key. Sorting
+// keys puts methods in the order they are declared in the interface.
+func declarationKey(key []int, i int) []int {
+	return append(key[:len(key):len(key)], i)
+}
+
+func lessKey(a, b []int) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
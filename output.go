@@ -0,0 +1,78 @@
+package impl
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// outputFile is the file that the generated methods are written to
+type outputFile struct {
+	name string
+	file *ast.File
+	fset *token.FileSet
+	src  []byte // content of a new file, nil if the file exists
+}
+
+// outputName returns the name of the file that the methods are written to
+// instead of the file declaring the concrete type, or "" if there is none.
+// A concrete type that must be declared goes to a new file named after it,
+// unless Config.Output names one.
+func outputName(cfg *Config, impl string, declare bool) string {
+	switch {
+	case cfg == nil:
+		return ""
+	case cfg.Output != "":
+		return cfg.Output
+	case declare:
+		return strings.ToLower(impl) + ".go"
+	}
+	return ""
+}
+
+// openOutput returns the named file in the concrete type's package.
+// A file name without a directory is relative to the package directory.
+// The file is parsed if it is not part of the loaded package, such as a file
+// excluded by build tags, and a new file only has a package clause.
+func openOutput(cfg *Config, implPkg *packages.Package, output string) (*outputFile, error) {
+	if len(implPkg.GoFiles) == 0 {
+		return nil, fmt.Errorf("could not find the directory of %v", implPkg.PkgPath)
+	}
+	dir := filepath.Dir(implPkg.GoFiles[0])
+	name := output
+	if filepath.Base(name) == name {
+		name = filepath.Join(dir, name)
+	}
+	name = cfg.abs(name)
+	if filepath.Dir(name) != dir {
+		return nil, fmt.Errorf("output file %v must be in the directory of %v: %v", output, implPkg.PkgPath, dir)
+	}
+	for _, f := range implPkg.Syntax {
+		if implPkg.Fset.Position(f.Pos()).Filename == name {
+			return &outputFile{name: name, file: f, fset: implPkg.Fset}, nil
+		}
+	}
+	out := &outputFile{name: name, fset: token.NewFileSet()}
+	src, err := cfg.readFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		src = []byte("package " + implPkg.Name + "\n")
+		out.src = src
+	} else if err != nil {
+		return nil, err
+	}
+	out.file, err = parser.ParseFile(out.fset, name, src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse output file: %w", err)
+	}
+	if out.file.Name.Name != implPkg.Name {
+		return nil, fmt.Errorf("output file %v belongs to package %v instead of %v", name, out.file.Name.Name, implPkg.Name)
+	}
+	return out, nil
+}
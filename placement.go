@@ -0,0 +1,123 @@
+package impl
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// PlacementKind defines where the generated methods are inserted
+type PlacementKind int
+
+// Placement kinds
+const (
+	// AfterTypePlacement inserts methods after the declaration of the
+	// concrete type, which is the whole type ( ... ) group if it has one.
+	AfterTypePlacement PlacementKind = iota
+	// AfterMethodsPlacement inserts methods after the last method of the
+	// concrete type declared in the same file, or after the type if none.
+	AfterMethodsPlacement
+	// EndOfFilePlacement inserts methods at the end of the file.
+	EndOfFilePlacement
+	// MarkerPlacement inserts methods after the InsertMarker
+	// comment in the concrete type's file.
+	MarkerPlacement
+)
+
+// InsertMarker is the comment that MarkerPlacement inserts methods after
+const InsertMarker = "//impl:insert-here"
+
+func (pk PlacementKind) String() string {
+	switch pk {
+	case AfterTypePlacement:
+		return "type"
+	case AfterMethodsPlacement:
+		return "methods"
+	case EndOfFilePlacement:
+		return "end"
+	case MarkerPlacement:
+		return "marker"
+	}
+	return fmt.Sprintf("PlacementKind(%d)", int(pk))
+}
+
+// ParsePlacementKind parses "type", "methods", "end" or "marker" into a PlacementKind
+func ParsePlacementKind(s string) (PlacementKind, error) {
+	for _, pk := range []PlacementKind{AfterTypePlacement, AfterMethodsPlacement, EndOfFilePlacement, MarkerPlacement} {
+		if strings.EqualFold(s, pk.String()) {
+			return pk, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown placement %q, expected type, methods, end or marker", s)
+}
+
+// insertPos returns the position of the concrete type's
+// file where the generated methods are inserted.
+func (tt *target) insertPos(cfg *Config) (token.Pos, error) {
+	placement := AfterTypePlacement
+	if cfg != nil {
+		placement = cfg.Placement
+	}
+	file := tt.ct.file
+	switch placement {
+	case AfterTypePlacement:
+	case AfterMethodsPlacement:
+		var last token.Pos
+		for _, decl := range file.Decls {
+			fd, ok := decl.(*ast.FuncDecl)
+			if ok && fd.Recv != nil && len(fd.Recv.List) == 1 && receiverName(fd.Recv.List[0].Type) == tt.impl {
+				last = fd.End()
+			}
+		}
+		if last.IsValid() {
+			return last, nil
+		}
+	case EndOfFilePlacement:
+		return tt.endOfFile(), nil
+	case MarkerPlacement:
+		for _, cg := range file.Comments {
+			for _, c := range cg.List {
+				if strings.TrimSpace(c.Text) == InsertMarker {
+					return c.End(), tt.checkMarker(c)
+				}
+			}
+		}
+		return token.NoPos, fmt.Errorf("could not find the %s marker in %s", InsertMarker, tt.implFilename)
+	default:
+		return token.NoPos, fmt.Errorf("unknown placement: %v", placement)
+	}
+	if !tt.declFile {
+		// the type is declared in another file
+		return tt.endOfFile(), nil
+	}
+	// insert after the whole declaration so that types
+	// declared in a type (...) group get their methods
+	// after the closing parenthesis.
+	nodes, _ := astutil.PathEnclosingInterval(file, tt.implObj.Pos(), tt.implObj.Pos())
+	return nodes[2].End(), nil
+}
+
+// checkMarker returns an error unless the marker is at the top level of the
+// file, after the package clause and outside of any declaration, since the
+// methods inserted after it would not parse anywhere else.
+func (tt *target) checkMarker(c *ast.Comment) error {
+	file := tt.ct.file
+	inside := c.Pos() < file.Name.End()
+	for _, decl := range file.Decls {
+		if decl.Pos() <= c.Pos() && c.End() <= decl.End() {
+			inside = true
+		}
+	}
+	if inside {
+		return fmt.Errorf("the %s marker at %v must be at the top level of the file, outside of any declaration", InsertMarker, tt.fset.Position(c.Pos()))
+	}
+	return nil
+}
+
+func (tt *target) endOfFile() token.Pos {
+	tf := tt.fset.File(tt.ct.file.Pos())
+	return tf.Pos(tf.Size())
+}
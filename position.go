@@ -0,0 +1,107 @@
+package impl
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"path/filepath"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// ImplementAt is like ImplementWithConfig but finds the concrete type from a position
+// in a Go file, such as an editor's cursor, instead of its import path and name.
+// Line and col are 1-based, and col counts bytes like the go command's positions.
+// The position can be anywhere within the type's declaration, including a type
+// declared in a grouped type (...) block, or within a method of the type.
+func ImplementAt(cfg *Config, ifacePath, iface, filename string, line, col int) (*Implementation, error) {
+	implPath, impl, err := TypeAt(cfg, filename, line, col)
+	if err != nil {
+		return nil, err
+	}
+	return ImplementWithConfig(cfg, ifacePath, iface, implPath, impl)
+}
+
+// TypeAt returns the import path and name of the type declared
+// at the given position of a Go file. See ImplementAt for details.
+func TypeAt(cfg *Config, filename string, line, col int) (string, string, error) {
+	abs := cfg.abs(filename)
+	mode := packages.NeedName | packages.NeedFiles | packages.NeedSyntax
+	pkgs, err := packages.Load(cfg.packagesConfig(mode), "file="+abs)
+	if err != nil {
+		return "", "", fmt.Errorf("error loading packages: %w", err)
+	}
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Syntax {
+			tf := pkg.Fset.File(f.Pos())
+			if tf == nil || filepath.Clean(tf.Name()) != abs {
+				continue
+			}
+			if line < 1 || line > tf.LineCount() {
+				return "", "", fmt.Errorf("%v:%d:%d: line out of range", filename, line, col)
+			}
+			// the column may point at the end of the line but not past it
+			end := tf.Base() + tf.Size()
+			if line < tf.LineCount() {
+				end = int(tf.LineStart(line + 1))
+			}
+			if col < 1 || int(tf.LineStart(line))+col > end {
+				return "", "", fmt.Errorf("%v:%d:%d: column out of range", filename, line, col)
+			}
+			pos := tf.LineStart(line) + token.Pos(col-1)
+			name, err := typeAt(f, pos)
+			if err != nil {
+				return "", "", fmt.Errorf("%v:%d:%d: %w", filename, line, col, err)
+			}
+			return pkg.PkgPath, name, nil
+		}
+	}
+	return "", "", fmt.Errorf("could not find a package for %v", filename)
+}
+
+// typeAt returns the name of the type declared at pos, or
+// the receiver type of the method declared at pos.
+func typeAt(f *ast.File, pos token.Pos) (string, error) {
+	path, _ := astutil.PathEnclosingInterval(f, pos, pos)
+	for _, n := range path {
+		switch n := n.(type) {
+		case *ast.TypeSpec:
+			return n.Name.Name, nil
+		case *ast.GenDecl:
+			if n.Tok != token.TYPE {
+				break
+			}
+			if len(n.Specs) == 1 {
+				return n.Specs[0].(*ast.TypeSpec).Name.Name, nil
+			}
+			return "", fmt.Errorf("position is within a group of %d types, move it to one of them", len(n.Specs))
+		case *ast.FuncDecl:
+			if n.Recv == nil || len(n.Recv.List) == 0 {
+				break
+			}
+			if name := receiverName(n.Recv.List[0].Type); name != "" {
+				return name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no type declaration found at position")
+}
+
+// receiverName returns the type name of a method receiver
+// such as *T, T or *T[K, V]
+func receiverName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(expr.X)
+	case *ast.ParenExpr:
+		return receiverName(expr.X)
+	case *ast.IndexExpr:
+		return receiverName(expr.X)
+	case *ast.IndexListExpr:
+		return receiverName(expr.X)
+	case *ast.Ident:
+		return expr.Name
+	}
+	return ""
+}
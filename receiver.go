@@ -0,0 +1,138 @@
+package impl
+
+import (
+	"fmt"
+	"go/types"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ReceiverKind defines the kind of receiver of the generated methods
+type ReceiverKind int
+
+// Receiver kinds
+const (
+	// PointerReceiver generates methods such as func (*T) Method()
+	PointerReceiver ReceiverKind = iota
+	// ValueReceiver generates methods such as func (T) Method()
+	ValueReceiver
+	// AutoReceiver uses the receiver kind of most of the type's
+	// existing methods. If the type has no methods, it uses value
+	// receivers for maps, channels and functions, and pointer
+	// receivers for everything else.
+	AutoReceiver
+)
+
+func (rk ReceiverKind) String() string {
+	switch rk {
+	case PointerReceiver:
+		return "pointer"
+	case ValueReceiver:
+		return "value"
+	case AutoReceiver:
+		return "auto"
+	}
+	return fmt.Sprintf("ReceiverKind(%d)", int(rk))
+}
+
+// ParseReceiverKind parses "pointer", "value" or "auto" into a ReceiverKind
+func ParseReceiverKind(s string) (ReceiverKind, error) {
+	for _, rk := range []ReceiverKind{PointerReceiver, ValueReceiver, AutoReceiver} {
+		if strings.EqualFold(s, rk.String()) {
+			return rk, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown receiver kind %q, expected pointer, value or auto", s)
+}
+
+// receiverKind returns the receiver kind to use for the target's
+// methods, resolving AutoReceiver from its existing methods.
+func (tt *target) receiverKind(cfg *Config) ReceiverKind {
+	if cfg == nil {
+		return PointerReceiver
+	}
+	if cfg.Receiver != AutoReceiver {
+		return cfg.Receiver
+	}
+	var pointers, values int
+	for i := 0; i < tt.ct.pms.Len(); i++ {
+		sel := tt.ct.pms.At(i)
+		// skip methods promoted from embedded fields
+		if len(sel.Index()) > 1 {
+			continue
+		}
+		recv := sel.Obj().(*types.Func).Type().(*types.Signature).Recv()
+		if _, ok := recv.Type().(*types.Pointer); ok {
+			pointers++
+		} else {
+			values++
+		}
+	}
+	switch {
+	case values > pointers:
+		return ValueReceiver
+	case pointers > 0:
+		return PointerReceiver
+	}
+	switch tt.implObj.Type().Underlying().(type) {
+	case *types.Map, *types.Chan, *types.Signature:
+		return ValueReceiver
+	}
+	return PointerReceiver
+}
+
+// receiverName returns the receiver name used by most of the type's
+// existing methods, or the configured name if there is none. An empty
+// name means the receiver is anonymous.
+func (tt *target) receiverName(cfg *Config) string {
+	counts := map[string]int{}
+	best := ""
+	for i := 0; i < tt.ct.pms.Len(); i++ {
+		sel := tt.ct.pms.At(i)
+		if len(sel.Index()) > 1 {
+			continue
+		}
+		name := sel.Obj().(*types.Func).Type().(*types.Signature).Recv().Name()
+		if name == "" || name == "_" {
+			continue
+		}
+		counts[name]++
+		if counts[name] > counts[best] || (counts[name] == counts[best] && name < best) {
+			best = name
+		}
+	}
+	if best != "" {
+		return best
+	}
+	// delegating methods refer to their receiver
+	if cfg != nil && cfg.ReceiverName == "_" && cfg.Delegate == "" {
+		return ""
+	}
+	if cfg != nil && cfg.ReceiverName != "" && cfg.ReceiverName != "_" {
+		return cfg.ReceiverName
+	}
+	for _, r := range tt.impl {
+		return string(unicode.ToLower(r))
+	}
+	return ""
+}
+
+// uniqueName returns name, or a variation of it, so that it does not
+// collide with any of the parameter or result names of sig.
+func uniqueName(name string, sig *types.Signature) string {
+	if name == "" {
+		return ""
+	}
+	taken := map[string]bool{}
+	for _, tuple := range []*types.Tuple{sig.Params(), sig.Results()} {
+		for i := 0; i < tuple.Len(); i++ {
+			taken[tuple.At(i).Name()] = true
+		}
+	}
+	candidate := name
+	for i := 2; taken[candidate]; i++ {
+		candidate = name + strconv.Itoa(i)
+	}
+	return candidate
+}
@@ -0,0 +1,170 @@
+package impl
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Session loads packages once and serves many Implement and ListInterfaces
+// queries against them, which avoids reloading the world on every call.
+// Once files change, call Invalidate so that the next query reloads them.
+// A Session is safe for concurrent use by multiple goroutines.
+type Session struct {
+	cfg *Config
+
+	mu       sync.Mutex
+	patterns map[string]struct{}
+	roots    []*packages.Package
+	pkgs     map[string]*packages.Package // all loaded packages by import path
+	dirs     map[string]struct{}          // directories of all loaded packages
+	files    map[string]struct{}          // files of all loaded packages
+	lists    map[string][]string          // ListInterfaces results by path
+}
+
+// NewSession returns a Session that loads the given package patterns, such
+// as "./...", right away. Packages outside of those patterns are loaded
+// on demand the first time a query needs them.
+func NewSession(cfg *Config, patterns ...string) (*Session, error) {
+	s := &Session{cfg: cfg, patterns: map[string]struct{}{}, lists: map[string][]string{}}
+	if len(patterns) == 0 {
+		return s, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range patterns {
+		s.patterns[p] = struct{}{}
+	}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Implement is like the package level Implement function
+// but uses the packages already loaded by the session.
+func (s *Session) Implement(ifacePath, iface, implPath, impl string) (*Implementation, error) {
+	return implement(s.cfg, s.load, ifacePath, iface, implPath, impl)
+}
+
+// Implements reports whether the concrete type already
+// implements all of the methods of the interface,
+// regardless of Config.Methods and Config.Exclude.
+func (s *Session) Implements(ifacePath, iface, implPath, impl string) (bool, error) {
+	tt, err := prepare(nil, s.load, ifacePath, iface, implPath, impl, newVisitedMethods())
+	if err != nil {
+		return false, err
+	}
+	return len(tt.missing) == 0, nil
+}
+
+// ListInterfaces is like the package level ListInterfaces
+// function but caches its results until the session is invalidated.
+func (s *Session) ListInterfaces(path string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ifaces, ok := s.lists[path]; ok {
+		return ifaces, nil
+	}
+	pkgs := []*packages.Package{}
+	if pkg, ok := s.pkgs[path]; ok {
+		pkgs = append(pkgs, pkg)
+	} else {
+		var err error
+		pkgs, err = loadPackage(s.cfg, path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ifaces, err := listAllInterfaces(pkgs)
+	if err != nil {
+		return nil, err
+	}
+	s.lists[path] = ifaces
+	return ifaces, nil
+}
+
+// Invalidate tells the session that the given files have changed, been
+// added or been removed, so that the next query reloads its packages.
+// Calling Invalidate with no files drops everything the session has loaded.
+func (s *Session) Invalidate(files ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lists = map[string][]string{}
+	if len(files) == 0 {
+		s.pkgs = nil
+		return
+	}
+	for _, f := range files {
+		f := s.cfg.abs(f)
+		_, known := s.files[f]
+		_, knownDir := s.dirs[filepath.Dir(f)]
+		if known || knownDir {
+			s.pkgs = nil
+			return
+		}
+	}
+}
+
+// load implements loadFunc by loading any package
+// that the session does not know about yet.
+func (s *Session) load(ifacePath, implPath string, extra ...string) (*packages.Package, *packages.Package, []*packages.Package, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	paths := append([]string{ifacePath, implPath}, extra...)
+	missing := []string{}
+	for _, p := range paths {
+		if _, ok := s.pkgs[p]; !ok {
+			missing = append(missing, p)
+		}
+	}
+	if s.pkgs == nil || len(missing) > 0 {
+		for _, p := range missing {
+			s.patterns[p] = struct{}{}
+		}
+		if err := s.reload(); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	ifacePkg, ok := s.pkgs[ifacePath]
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("missing interface package info for %v", ifacePath)
+	}
+	implPkg, ok := s.pkgs[implPath]
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("missing implementation package info for %v", implPath)
+	}
+	return ifacePkg, implPkg, s.roots, nil
+}
+
+// reload loads all of the session's patterns at once so that every
+// package shares the same type information. It must be called with s.mu held.
+func (s *Session) reload() error {
+	patterns := make([]string, 0, len(s.patterns))
+	for p := range s.patterns {
+		patterns = append(patterns, p)
+	}
+	sort.Strings(patterns)
+	roots, err := packages.Load(s.cfg.packagesConfig(loadMode), patterns...)
+	if err != nil {
+		return fmt.Errorf("error loading packages: %w", err)
+	}
+	s.roots = roots
+	s.pkgs = map[string]*packages.Package{}
+	s.dirs = map[string]struct{}{}
+	s.files = map[string]struct{}{}
+	packages.Visit(roots, nil, func(p *packages.Package) {
+		if p.Types == nil {
+			return
+		}
+		s.pkgs[p.PkgPath] = p
+		for _, f := range p.GoFiles {
+			s.files[f] = struct{}{}
+			s.dirs[filepath.Dir(f)] = struct{}{}
+		}
+	})
+	return nil
+}
@@ -0,0 +1,322 @@
+package impl
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// DefaultTemplate is the text/template used to generate
+// each method when Config.Template is empty.
+const DefaultTemplate = `{{ .Comment }}
+func ({{ .Receiver }}) {{ .Name }}{{ .Signature }} {
+{{- with .Body }}
+	{{ . }}
+{{- end }}
+}
+`
+
+// MethodData is the data passed to the method template for each generated method.
+// All types are written relative to the concrete type's file, such as models.User
+// or just User when the concrete type is in the models package.
+type MethodData struct {
+	Name            string   // name of the method such as "Read"
+	Doc             string   // doc comment of the method in the interface, without comment markers
+	Comment         string   // doc comment of the generated method according to Config.Doc, with comment markers
+	Interface       string   // name of the implemented interface such as "ReadCloser"
+	InterfacePath   string   // import path of the implemented interface such as "io"
+	Origin          string   // name of the interface that declares the method, such as "Reader" or "Getter[int]" with its type arguments
+	OriginPath      string   // import path of the interface that declares the method
+	Chain           []string // interfaces embedding down to Origin, such as [Partier io.ReadCloser io.Reader]
+	Implements      string   // Origin relative to the concrete type such as io.Reader, or its Chain with Config.Chain
+	Implementer     string   // name of the concrete type such as "File"
+	Receiver        string   // receiver declaration such as "f *File", "*File" or "c *Cache[K, V]"
+	ReceiverName    string   // name of the receiver such as "f", empty for anonymous receivers
+	ReceiverType    string   // type of the receiver such as "*File" or "File"
+	PointerReceiver bool     // whether the receiver is a pointer
+	Signature       string   // parameters and results such as "(p []byte) (n int, err error)"
+	Params          []Var    // parameters of the method, the last one's type starts with "..." if Variadic
+	Results         []Var    // results of the method
+	Variadic        bool     // whether the last parameter is variadic
+	ReturnsError    bool     // whether the last result is an error
+
+	body func() (string, error)
+}
+
+// Body returns the statements of the method according to Config.Body or
+// Config.Delegate, such as panic("unimplemented"), return 0, nil or
+// return t.next.Read(p).
+func (md *MethodData) Body() (string, error) {
+	if md.body == nil {
+		return `panic("unimplemented")`, nil
+	}
+	return md.body()
+}
+
+// localNames returns the names declared by the method's
+// receiver, parameters and named results.
+func (md *MethodData) localNames() []string {
+	names := []string{}
+	if md.ReceiverName != "" {
+		names = append(names, md.ReceiverName)
+	}
+	for _, vars := range [][]Var{md.Params, md.Results} {
+		for _, v := range vars {
+			if v.Name != "" && v.Name != "_" {
+				names = append(names, v.Name)
+			}
+		}
+	}
+	return names
+}
+
+// Var is a parameter or a result of a method.
+// Name is empty if the parameter or result is unnamed.
+type Var struct {
+	Name string
+	Type string
+	typ  types.Type
+}
+
+// Besides the text/template builtins, method templates can call:
+//
+//	zero       the zero value literal of a Var's type, such as 0, "", nil or T{}
+//	qualify    prints a type such as "*example.com/models.User", whose packages
+//	           are full import paths of loaded packages, relative to the
+//	           concrete type's file, adding an import if necessary
+//	paramNames returns the given Vars with names for the unnamed ones
+//	import     imports a package such as "errors" in the concrete type's file
+//	           and returns its name, which is aliased if the name is taken
+//
+// For example, the following returns zero values from every method:
+//
+//	func ({{ .Receiver }}) {{ .Name }}{{ .Signature }} {
+//		return {{ range $i, $r := .Results }}{{ if $i }}, {{ end }}{{ zero $r }}{{ end }}
+//	}
+func (ct *concreteType) templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"zero":       ct.zero,
+		"qualify":    ct.qualify,
+		"paramNames": paramNames,
+		"import":     ct.importPath,
+	}
+}
+
+// methodTemplate parses the configured method template or the default one
+func methodTemplate(cfg *Config, funcs template.FuncMap) (*template.Template, error) {
+	text := DefaultTemplate
+	if cfg != nil && cfg.Template != "" {
+		text = cfg.Template
+	}
+	t, err := template.New("method").Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse method template: %w", err)
+	}
+	return t, nil
+}
+
+// signatureVars returns the parameters and results of a rewritten method signature
+func signatureVars(ft *ast.FuncType, sig *types.Signature, fset *token.FileSet) (params, results []Var, err error) {
+	params, err = fieldVars(ft.Params, sig.Params(), fset)
+	if err != nil {
+		return nil, nil, err
+	}
+	results, err = fieldVars(ft.Results, sig.Results(), fset)
+	if err != nil {
+		return nil, nil, err
+	}
+	return params, results, nil
+}
+
+func fieldVars(fl *ast.FieldList, tuple *types.Tuple, fset *token.FileSet) ([]Var, error) {
+	vars := []Var{}
+	if fl == nil {
+		return vars, nil
+	}
+	for _, f := range fl.List {
+		var typ bytes.Buffer
+		if err := format.Node(&typ, fset, f.Type); err != nil {
+			return nil, fmt.Errorf("could not format type: %w", err)
+		}
+		if len(f.Names) == 0 {
+			vars = append(vars, Var{Type: typ.String(), typ: tuple.At(len(vars)).Type()})
+			continue
+		}
+		for _, name := range f.Names {
+			vars = append(vars, Var{Name: name.Name, Type: typ.String(), typ: tuple.At(len(vars)).Type()})
+		}
+	}
+	return vars, nil
+}
+
+// zero returns the zero value literal of v's type
+func (ct *concreteType) zero(v Var) (string, error) {
+	if v.typ == nil {
+		return "", fmt.Errorf("unknown type for %q", v.Type)
+	}
+	return ct.zeroValue(v.typ), nil
+}
+
+func (ct *concreteType) zeroValue(t types.Type) string {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsBoolean != 0:
+			return "false"
+		case u.Info()&types.IsNumeric != 0:
+			return "0"
+		case u.Info()&types.IsString != 0:
+			return `""`
+		}
+		return "nil"
+	case *types.Struct, *types.Array:
+		return types.TypeString(t, ct.qualifier) + "{}"
+	case *types.Interface:
+		if _, ok := t.(*types.TypeParam); ok {
+			// the zero value of a type parameter
+			// cannot be spelled as a literal.
+			return "*new(" + types.TypeString(t, ct.qualifier) + ")"
+		}
+	}
+	return "nil"
+}
+
+// qualify prints a type expression whose packages are spelled out as full
+// import paths relative to the concrete type's file, such as turning
+// "*example.com/models.User" into "*models.User", or "*User" if the
+// concrete type lives in the models package. The types of MethodData are
+// already relative to the concrete type's file and must not be qualified again.
+func (ct *concreteType) qualify(typ string) (string, error) {
+	variadic := strings.HasPrefix(typ, "...")
+	src, paths := replaceImportPaths(strings.TrimPrefix(typ, "..."))
+	expr, err := parser.ParseExpr(src)
+	if err != nil {
+		return "", fmt.Errorf("could not parse type %q: %w", typ, err)
+	}
+	var qualifyErr error
+	expr = astutil.Apply(expr, func(c *astutil.Cursor) bool {
+		sel, ok := c.Node().(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		x, ok := sel.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		// an import path without a slash such as "context"
+		// is not replaced by a placeholder.
+		importPath, ok := paths[x.Name]
+		if !ok {
+			importPath = x.Name
+		}
+		pkg := ct.findPackage(importPath)
+		if pkg == nil {
+			qualifyErr = fmt.Errorf("could not qualify %q: package %q is not loaded", typ, importPath)
+			return false
+		}
+		if name := ct.qualifier(pkg); name != "" {
+			c.Replace(&ast.SelectorExpr{X: ast.NewIdent(name), Sel: sel.Sel})
+		} else {
+			c.Replace(sel.Sel)
+		}
+		return false
+	}, nil).(ast.Expr)
+	if qualifyErr != nil {
+		return "", qualifyErr
+	}
+	if variadic {
+		return "..." + types.ExprString(expr), nil
+	}
+	return types.ExprString(expr), nil
+}
+
+// importPath imports the package with the given import path
+// in the concrete type's file and returns its name. The name of a
+// package that is not loaded is guessed from its path, and the
+// package is imported under that name as an explicit alias.
+func (ct *concreteType) importPath(importPath string) (string, error) {
+	if importPath == ct.pkg.Path() {
+		return "", fmt.Errorf("cannot import %q into itself", importPath)
+	}
+	if pkg := ct.findPackage(importPath); pkg != nil {
+		return ct.importName(pkg), nil
+	}
+	name := guessPackageName(importPath)
+	if name == "" {
+		return "", fmt.Errorf("cannot import %q: package is not loaded and its name cannot be derived from its path", importPath)
+	}
+	return ct.importAs(types.NewPackage(importPath, name), true), nil
+}
+
+// versionSuffix matches the major version suffix of an import
+// path, such as /v2 in example.com/foo/v2 or .v2 in gopkg.in/yaml.v2
+var versionSuffix = regexp.MustCompile(`[/.]v[0-9]+$`)
+
+// guessPackageName derives a package name from an import path, such as
+// yaml for gopkg.in/yaml.v2 or foobar for example.com/foo-bar/v2,
+// by dropping the characters that are not allowed in identifiers.
+// It returns "" if the path does not make a valid identifier.
+func guessPackageName(importPath string) string {
+	base := path.Base(versionSuffix.ReplaceAllString(importPath, ""))
+	name := strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, base)
+	if !token.IsIdentifier(name) || name == "_" {
+		return ""
+	}
+	return name
+}
+
+// findPackage returns the loaded package with the given
+// import path, or nil if it is not one of them.
+func (ct *concreteType) findPackage(importPath string) *types.Package {
+	if importPath == ct.pkg.Path() {
+		return ct.pkg
+	}
+	return ct.pkgs[importPath]
+}
+
+// paramNames returns vars with names for the unnamed or blank ones,
+// such as p0 and p1, so that they can be referred to in method bodies.
+func paramNames(vars []Var) []Var {
+	taken := map[string]bool{}
+	for _, v := range vars {
+		taken[v.Name] = true
+	}
+	named := make([]Var, len(vars))
+	for i, v := range vars {
+		if v.Name == "" || v.Name == "_" {
+			v.Name = "p" + strconv.Itoa(i)
+			for taken[v.Name] {
+				v.Name += "_"
+			}
+			taken[v.Name] = true
+		}
+		named[i] = v
+	}
+	return named
+}
+
+// returnsError reports whether the last result of sig is the error type
+func returnsError(sig *types.Signature) bool {
+	results := sig.Results()
+	if results.Len() == 0 {
+		return false
+	}
+	return types.Identical(results.At(results.Len()-1).Type(), types.Universe.Lookup("error").Type())
+}
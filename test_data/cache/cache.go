@@ -0,0 +1,11 @@
+package cache
+
+// Cache is a generic in-memory cache
+type Cache[K comparable, V any] struct {
+	items map[K]V
+}
+
+// Get returns the cached value of k
+func (c *Cache[K, V]) Get(k K) (V, error) {
+	return c.items[k], nil
+}
@@ -0,0 +1,21 @@
+package constraint
+
+// Stringish is a constraint with a type set
+type Stringish interface {
+	~int | ~string
+	String() string
+}
+
+// Comparer embeds comparable
+type Comparer interface {
+	comparable
+	Compare() int
+}
+
+// Stringer is a regular interface
+type Stringer interface {
+	String() string
+}
+
+// Value can implement the constraints methods
+type Value struct{}
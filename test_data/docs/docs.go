@@ -0,0 +1,18 @@
+package docs
+
+// Cache stores values for a while
+type Cache interface {
+	// Get returns the value of key and
+	// whether it was found.
+	Get(key string) (string, bool)
+
+	// Set stores the value of key.
+	//
+	// It overwrites any existing value.
+	Set(key, value string)
+
+	Len() int
+}
+
+// LRU is a least recently used Cache
+type LRU struct{}
@@ -0,0 +1,19 @@
+package grouped
+
+type (
+	// First is the first type of the group
+	First struct{}
+
+	// Second is the second type of the group
+	Second struct {
+		Name string
+	}
+)
+
+// Third is declared on its own
+type Third int
+
+// String returns the name of Second
+func (s *Second) String() string {
+	return s.Name
+}
@@ -0,0 +1,17 @@
+package health
+
+import "context"
+
+// Healthchecker reports whether a service is healthy
+type Healthchecker interface {
+	Healthcheck(ctx context.Context) error
+	Close() error
+}
+
+// Stopper stops a service without reporting errors
+type Stopper interface {
+	Close()
+}
+
+// Handler handles requests and reports its health
+type Handler struct{}
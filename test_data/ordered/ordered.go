@@ -0,0 +1,15 @@
+package ordered
+
+import "io"
+
+// Conn is a connection whose methods are not sorted by name
+type Conn interface {
+	Open(addr string) error
+	io.Reader
+	Ping() error
+	io.WriteCloser
+	Deadline() int
+}
+
+// Client is a Conn
+type Client struct{}
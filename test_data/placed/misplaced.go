@@ -0,0 +1,8 @@
+package placed
+
+// Gateway is declared in a file whose marker is inside a function
+type Gateway struct{}
+
+func route() {
+	//impl:insert-here
+}
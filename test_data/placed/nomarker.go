@@ -0,0 +1,4 @@
+package placed
+
+// Proxy is declared in a file without a marker
+type Proxy struct{}
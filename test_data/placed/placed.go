@@ -0,0 +1,21 @@
+package placed
+
+// Server serves requests
+type Server struct{}
+
+// Start starts the server
+func (s *Server) Start() {}
+
+func helper() {}
+
+// Stop stops the server
+func (s *Server) Stop() {}
+
+// Client has no methods
+type Client struct{}
+
+var defaultClient = Client{}
+
+//impl:insert-here
+
+func trailing() {}
@@ -0,0 +1,9 @@
+package printer
+
+// Printer formats and prints messages
+type Printer interface {
+	Printf(fmt string, args ...any) (int, error)
+}
+
+// Console names a parameter after the fmt package
+type Console struct{}
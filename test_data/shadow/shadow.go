@@ -0,0 +1,7 @@
+package shadow
+
+// Service declares a variable that
+// shadows the name of the errors package
+type Service struct{}
+
+var errors []error
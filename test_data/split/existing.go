@@ -0,0 +1,8 @@
+package split
+
+import stdio "io"
+
+// Read implements io.Reader
+func (s *Store) Read(p []byte) (n int, err error) {
+	return 0, stdio.EOF
+}
@@ -0,0 +1,4 @@
+package split
+
+// Store keeps its interface implementations in other files
+type Store struct{}
@@ -0,0 +1,11 @@
+package models
+
+// Item is something a shop sells
+type Item struct {
+	Name string
+}
+
+// Stocker restocks items
+type Stocker interface {
+	Restock(items []*Item) error
+}
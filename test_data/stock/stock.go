@@ -0,0 +1,11 @@
+package stock
+
+import "marwan.io/impl/test_data/models"
+
+// Host invites people over
+type Host interface {
+	Invite(p *models.Person) error
+}
+
+// Shop shares a package name with both interfaces
+type Shop struct{}
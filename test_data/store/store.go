@@ -0,0 +1,16 @@
+package store
+
+import "marwan.io/impl/test_data/models"
+
+// Getter retrieves values by their key
+type Getter[K comparable, V any] interface {
+	Get(K) (V, error)
+}
+
+// Store is a generic key value store
+type Store[K comparable, V any] interface {
+	Getter[K, V]
+	Put(key K, value V) error
+	All() map[K]V
+	Owner(K) *models.Person
+}
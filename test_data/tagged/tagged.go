@@ -0,0 +1,2 @@
+// Package tagged declares its types behind a build tag
+package tagged
@@ -0,0 +1,11 @@
+//go:build impltag
+
+package tagged
+
+// Tagged only exists with the impltag build tag
+type Tagged struct{}
+
+// Tagger only exists with the impltag build tag
+type Tagger interface {
+	Tag() string
+}
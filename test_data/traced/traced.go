@@ -0,0 +1,33 @@
+package traced
+
+import "io"
+
+// Store is a key value store
+type Store interface {
+	Get(string) ([]byte, error)
+	Put(key string, _ []byte) error
+	Keys(prefix string, limits ...int) []string
+	Close()
+}
+
+// tracedStore traces the calls to the next Store
+type tracedStore struct {
+	next Store
+}
+
+// readerStore does not have the methods of a Store
+type readerStore struct {
+	next io.Reader
+}
+
+// mapStore has a Get method that returns a different type
+type mapStore struct {
+	next mapGetter
+}
+
+type mapGetter interface {
+	Get(string) (map[string]string, error)
+	Put(key string, _ []byte) error
+	Keys(prefix string, limits ...int) []string
+	Close()
+}
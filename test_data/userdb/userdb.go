@@ -0,0 +1,11 @@
+package userdb
+
+// UserDB stores users in memory
+type UserDB struct {
+	users map[string]*User
+}
+
+// User of the database
+type User struct {
+	Name string
+}
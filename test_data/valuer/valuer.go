@@ -0,0 +1,24 @@
+package valuer
+
+// Valuer mostly has value receivers
+type Valuer struct {
+	Name string
+}
+
+// Handlers is a map without methods
+type Handlers map[string]func()
+
+// First is a value method
+func (v Valuer) First() string {
+	return v.Name
+}
+
+// Second is a value method
+func (v Valuer) Second() string {
+	return v.Name
+}
+
+// Set is a pointer method
+func (v *Valuer) Set(name string) {
+	v.Name = name
+}
@@ -0,0 +1,349 @@
+package impl
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// typeSpec is a parsed type name that may carry type arguments,
+// such as "Store" or "Store[string, *models.User]"
+type typeSpec struct {
+	name  string
+	args  []ast.Expr
+	paths map[string]string // placeholder identifier -> full import path
+}
+
+// fullPathQualifier matches package qualifiers that are spelled out
+// as full import paths such as example.com/models.User
+var fullPathQualifier = regexp.MustCompile(`([A-Za-z0-9_\-.~]+(?:/[A-Za-z0-9_\-.~]+)+)\.([A-Za-z_][A-Za-z0-9_]*)`)
+
+// parseTypeSpec parses a type name with optional type arguments. Type arguments
+// are Go type expressions whose package qualifiers are either package names
+// visible to the concrete type or the interface, or full import paths
+// like example.com/models.User.
+func parseTypeSpec(s string) (*typeSpec, error) {
+	s = strings.TrimSpace(s)
+	idx := strings.Index(s, "[")
+	if idx == -1 {
+		return &typeSpec{name: s}, nil
+	}
+	if !strings.HasSuffix(s, "]") {
+		return nil, fmt.Errorf("malformed type arguments in %q", s)
+	}
+	ts := &typeSpec{name: strings.TrimSpace(s[:idx])}
+	args, paths := replaceImportPaths(s[idx+1 : len(s)-1])
+	ts.paths = paths
+	// parse the arguments as an index expression so that
+	// the parser takes care of splitting them up.
+	expr, err := parser.ParseExpr("_[" + args + "]")
+	if err != nil {
+		return nil, fmt.Errorf("could not parse type arguments of %q: %w", s, err)
+	}
+	switch expr := expr.(type) {
+	case *ast.IndexExpr:
+		ts.args = []ast.Expr{expr.Index}
+	case *ast.IndexListExpr:
+		ts.args = expr.Indices
+	default:
+		return nil, fmt.Errorf("could not parse type arguments of %q", s)
+	}
+	return ts, nil
+}
+
+// replaceImportPaths replaces the full import paths that qualify the types
+// of a type expression with placeholder identifiers, which turns it into
+// valid Go syntax. It returns the new expression and the import path of
+// each placeholder.
+func replaceImportPaths(s string) (string, map[string]string) {
+	paths := map[string]string{}
+	s = fullPathQualifier.ReplaceAllStringFunc(s, func(m string) string {
+		sub := fullPathQualifier.FindStringSubmatch(m)
+		placeholder := "_impl_pkg" + strconv.Itoa(len(paths))
+		paths[placeholder] = sub[1]
+		return placeholder + "." + sub[2]
+	})
+	return s, paths
+}
+
+// importPaths returns the full import paths referenced by the type arguments
+// so that they can be loaded alongside the interface and concrete type.
+func (ts *typeSpec) importPaths() []string {
+	paths := []string{}
+	for _, p := range ts.paths {
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+// typeResolver turns type argument expressions into types.Type
+// by looking up identifiers in the type parameters of the concrete type,
+// then in the scope of the concrete type and the interface, in that order.
+type typeResolver struct {
+	spec    *typeSpec
+	tparams *types.TypeParamList // type parameters of a generic concrete type
+	scopes  []*types.Scope
+	files   []*ast.File
+	pkgs    map[string]*types.Package
+}
+
+func newTypeResolver(spec *typeSpec, scopes, roots []*packages.Package, files []*ast.File) *typeResolver {
+	r := &typeResolver{spec: spec, files: files, pkgs: map[string]*types.Package{}}
+	for _, p := range scopes {
+		r.scopes = append(r.scopes, p.Types.Scope())
+	}
+	packages.Visit(roots, nil, func(p *packages.Package) {
+		if p.Types != nil {
+			r.pkgs[p.PkgPath] = p.Types
+		}
+	})
+	return r
+}
+
+func (r *typeResolver) resolveAll() ([]types.Type, error) {
+	targs := []types.Type{}
+	for _, arg := range r.spec.args {
+		t, err := r.resolve(arg)
+		if err != nil {
+			return nil, err
+		}
+		targs = append(targs, t)
+	}
+	return targs, nil
+}
+
+func (r *typeResolver) resolve(e ast.Expr) (types.Type, error) {
+	switch e := e.(type) {
+	case *ast.Ident:
+		return r.lookupType(e.Name)
+	case *ast.SelectorExpr:
+		x, ok := e.X.(*ast.Ident)
+		if !ok {
+			return nil, fmt.Errorf("unsupported type argument: %v", types.ExprString(e))
+		}
+		pkg, err := r.lookupPackage(x.Name)
+		if err != nil {
+			return nil, err
+		}
+		tn, ok := pkg.Scope().Lookup(e.Sel.Name).(*types.TypeName)
+		if !ok {
+			return nil, fmt.Errorf("could not find type %s in %s", e.Sel.Name, pkg.Path())
+		}
+		return tn.Type(), nil
+	case *ast.ParenExpr:
+		return r.resolve(e.X)
+	case *ast.StarExpr:
+		elem, err := r.resolve(e.X)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewPointer(elem), nil
+	case *ast.ArrayType:
+		elem, err := r.resolve(e.Elt)
+		if err != nil {
+			return nil, err
+		}
+		if e.Len == nil {
+			return types.NewSlice(elem), nil
+		}
+		lit, ok := e.Len.(*ast.BasicLit)
+		if !ok || lit.Kind != token.INT {
+			return nil, fmt.Errorf("unsupported array length in type argument: %v", types.ExprString(e))
+		}
+		n, err := strconv.ParseInt(lit.Value, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid array length %v: %w", lit.Value, err)
+		}
+		return types.NewArray(elem, n), nil
+	case *ast.MapType:
+		key, err := r.resolve(e.Key)
+		if err != nil {
+			return nil, err
+		}
+		val, err := r.resolve(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewMap(key, val), nil
+	case *ast.ChanType:
+		elem, err := r.resolve(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		dir := types.SendRecv
+		if e.Dir == ast.SEND {
+			dir = types.SendOnly
+		} else if e.Dir == ast.RECV {
+			dir = types.RecvOnly
+		}
+		return types.NewChan(dir, elem), nil
+	case *ast.FuncType:
+		params, variadic, err := r.resolveFields(e.Params)
+		if err != nil {
+			return nil, err
+		}
+		results, _, err := r.resolveFields(e.Results)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewSignatureType(nil, nil, nil, params, results, variadic), nil
+	case *ast.InterfaceType:
+		if e.Methods != nil && len(e.Methods.List) > 0 {
+			return nil, fmt.Errorf("only empty interfaces are supported as type arguments: %v", types.ExprString(e))
+		}
+		return types.NewInterfaceType(nil, nil).Complete(), nil
+	case *ast.StructType:
+		if e.Fields != nil && len(e.Fields.List) > 0 {
+			return nil, fmt.Errorf("only empty structs are supported as type arguments: %v", types.ExprString(e))
+		}
+		return types.NewStruct(nil, nil), nil
+	case *ast.IndexExpr:
+		return r.instantiate(e.X, []ast.Expr{e.Index})
+	case *ast.IndexListExpr:
+		return r.instantiate(e.X, e.Indices)
+	}
+	return nil, fmt.Errorf("unsupported type argument: %v", types.ExprString(e))
+}
+
+func (r *typeResolver) resolveFields(fl *ast.FieldList) (*types.Tuple, bool, error) {
+	if fl == nil {
+		return nil, false, nil
+	}
+	vars := []*types.Var{}
+	variadic := false
+	for _, f := range fl.List {
+		expr := f.Type
+		if ell, ok := expr.(*ast.Ellipsis); ok {
+			variadic = true
+			expr = &ast.ArrayType{Elt: ell.Elt}
+		}
+		t, err := r.resolve(expr)
+		if err != nil {
+			return nil, false, err
+		}
+		n := len(f.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			vars = append(vars, types.NewParam(token.NoPos, nil, "", t))
+		}
+	}
+	return types.NewTuple(vars...), variadic, nil
+}
+
+func (r *typeResolver) instantiate(x ast.Expr, indices []ast.Expr) (types.Type, error) {
+	t, err := r.resolve(x)
+	if err != nil {
+		return nil, err
+	}
+	targs := []types.Type{}
+	for _, idx := range indices {
+		targ, err := r.resolve(idx)
+		if err != nil {
+			return nil, err
+		}
+		targs = append(targs, targ)
+	}
+	inst, err := types.Instantiate(nil, t, targs, true)
+	if err != nil {
+		return nil, fmt.Errorf("could not instantiate %v: %w", types.ExprString(x), err)
+	}
+	return inst, nil
+}
+
+func (r *typeResolver) lookupType(name string) (types.Type, error) {
+	for i := 0; i < r.tparams.Len(); i++ {
+		if tp := r.tparams.At(i); tp.Obj().Name() == name {
+			return tp, nil
+		}
+	}
+	if tn, ok := types.Universe.Lookup(name).(*types.TypeName); ok {
+		return tn.Type(), nil
+	}
+	for _, s := range r.scopes {
+		if tn, ok := s.Lookup(name).(*types.TypeName); ok {
+			return tn.Type(), nil
+		}
+	}
+	return nil, fmt.Errorf("could not find type %q for type argument", name)
+}
+
+// lookupPackage resolves a package qualifier by first checking
+// full import path placeholders, then the imports of the
+// concrete type and interface files and lastly any loaded package
+// whose import path matches the qualifier.
+func (r *typeResolver) lookupPackage(name string) (*types.Package, error) {
+	if path, ok := r.spec.paths[name]; ok {
+		if pkg, ok := r.pkgs[path]; ok {
+			return pkg, nil
+		}
+		return nil, fmt.Errorf("could not load package %q for type argument", path)
+	}
+	for _, f := range r.files {
+		if f == nil {
+			continue
+		}
+		for _, imp := range f.Imports {
+			if isIgnoredImport(imp) {
+				continue
+			}
+			impPath, _ := strconv.Unquote(imp.Path.Value)
+			pkg, ok := r.pkgs[impPath]
+			if !ok {
+				continue
+			}
+			if (imp.Name != nil && imp.Name.Name == name) || (imp.Name == nil && pkg.Name() == name) {
+				return pkg, nil
+			}
+		}
+	}
+	if pkg, ok := r.pkgs[name]; ok {
+		return pkg, nil
+	}
+	return nil, fmt.Errorf("could not find package %q for type argument, try using its full import path", name)
+}
+
+// typeParams returns the type parameters of a generic named type, or nil.
+func typeParams(t types.Type) *types.TypeParamList {
+	named, ok := types.Unalias(t).(*types.Named)
+	if !ok {
+		return nil
+	}
+	return named.TypeParams()
+}
+
+// receiverType returns the receiver type expression of a concrete type,
+// which carries its type parameter list if it is generic, such as "Cache[K, V]".
+func receiverType(name string, tparams *types.TypeParamList) string {
+	if tparams.Len() == 0 {
+		return name
+	}
+	names := make([]string, tparams.Len())
+	for i := range names {
+		names[i] = tparams.At(i).Obj().Name()
+	}
+	return name + "[" + strings.Join(names, ", ") + "]"
+}
+
+// typeParamMap maps the type parameters of a generic named type to
+// the type arguments it was instantiated with. It returns nil if t is
+// not an instantiated type.
+func typeParamMap(t *types.Named) map[*types.TypeParam]types.Type {
+	tparams, targs := t.TypeParams(), t.TypeArgs()
+	if tparams.Len() == 0 || targs.Len() != tparams.Len() {
+		return nil
+	}
+	subst := map[*types.TypeParam]types.Type{}
+	for i := 0; i < tparams.Len(); i++ {
+		subst[tparams.At(i)] = targs.At(i)
+	}
+	return subst
+}